@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/rkspx/elastic-syncer/syncer"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "write elasticsearch indices and their documents to NDJSON files, one directory per index",
+	Run:   export,
+}
+
+func init() {
+	exportCmd.Flags().Duration("since", syncer.DefaultSince, "export all documents that dated since the specified duration, only works if the index has a time field with 'date' or 'date_nanos' field type, default: 30d")
+	exportCmd.Flags().Int("limit", syncer.DefaultLimit, "limit number of exported document, set to 0 to disable, default: 0")
+	exportCmd.Flags().String("index", syncer.DefaultIndex, "index name")
+	exportCmd.Flags().String("time-field", syncer.DefaultTimeField, "time field used to sort and filter documents, use dotted path for nested fields, detected from the index mappings if empty")
+	exportCmd.Flags().Int("slices", syncer.DefaultSlices, "number of slices each point-in-time is split into and read concurrently, default: 1")
+	exportCmd.Flags().Int("page-size", syncer.DefaultPageSize, "number of documents read per search request, default: 1000")
+	exportCmd.Flags().String("read-mode", syncer.DefaultReadMode, "how documents are read from the source, one of 'auto', 'pit', 'scroll' or 'paginate', default: auto")
+	exportCmd.Flags().String("query", "", "query DSL selecting the documents to export, either inline JSON or '@' followed by a file path")
+	exportCmd.Flags().String("q", "", "lucene query string selecting the documents to export")
+	exportCmd.Flags().StringSlice("source-includes", nil, "comma separated document fields to export, wildcards are supported, all fields are exported if empty")
+	exportCmd.Flags().StringSlice("source-excludes", nil, "comma separated document fields to not export, wildcards are supported")
+	exportCmd.Flags().String("dir", "", "directory where every index is exported to its own directory, along with the manifest")
+	exportCmd.Flags().Bool("gzip", false, "gzip the documents files")
+	exportCmd.Flags().Int64("max-file-size", 0, "size in bytes above which documents are written to a new file, set to 0 to write every document of an index to one file, default: 0")
+	exportCmd.Flags().String("from-address", "", "source elasticsearch address")
+	exportCmd.Flags().String("from-username", "", "source elasticsearch username, if using basic authentication")
+	exportCmd.Flags().String("from-password", "", "source elasticsearch password, if using basic authentication")
+	exportCmd.Flags().Bool("log-from-requests", false, "log source elasticsearch requests")
+	exportCmd.Flags().Bool("log-from-responses", false, "log source elasticsearch requests")
+
+	rootCmd.AddCommand(exportCmd)
+}
+
+func export(cmd *cobra.Command, args []string) {
+	since, err := cmd.Flags().GetDuration("since")
+	if err != nil {
+		log.Fatalf("can not get 'since' value, %v", err)
+	}
+
+	limit, err := cmd.Flags().GetInt("limit")
+	if err != nil {
+		log.Fatalf("can not get 'limit' value, %v", err)
+	}
+
+	index, err := cmd.Flags().GetString("index")
+	if err != nil {
+		log.Fatalf("can not get 'index' value, %v", err)
+	}
+
+	timeField, err := cmd.Flags().GetString("time-field")
+	if err != nil {
+		log.Fatalf("can not get 'time-field' value, %v", err)
+	}
+
+	slices, err := cmd.Flags().GetInt("slices")
+	if err != nil {
+		log.Fatalf("can not get 'slices' value, %v", err)
+	}
+
+	pageSize, err := cmd.Flags().GetInt("page-size")
+	if err != nil {
+		log.Fatalf("can not get 'page-size' value, %v", err)
+	}
+
+	readMode, err := cmd.Flags().GetString("read-mode")
+	if err != nil {
+		log.Fatalf("can not get 'read-mode' value, %v", err)
+	}
+
+	query, err := cmd.Flags().GetString("query")
+	if err != nil {
+		log.Fatalf("can not get 'query' value, %v", err)
+	}
+
+	queryDSL, err := readQuery(query)
+	if err != nil {
+		log.Fatalf("can not read 'query' value, %v", err)
+	}
+
+	queryString, err := cmd.Flags().GetString("q")
+	if err != nil {
+		log.Fatalf("can not get 'q' value, %v", err)
+	}
+
+	sourceIncludes, err := cmd.Flags().GetStringSlice("source-includes")
+	if err != nil {
+		log.Fatalf("can not get 'source-includes' value, %v", err)
+	}
+
+	sourceExcludes, err := cmd.Flags().GetStringSlice("source-excludes")
+	if err != nil {
+		log.Fatalf("can not get 'source-excludes' value, %v", err)
+	}
+
+	dir, err := cmd.Flags().GetString("dir")
+	if err != nil {
+		log.Fatalf("can not get 'dir' value, %v", err)
+	}
+
+	if dir == "" {
+		log.Fatal("'dir' value is required")
+	}
+
+	gzip, err := cmd.Flags().GetBool("gzip")
+	if err != nil {
+		log.Fatalf("can not get 'gzip' value, %v", err)
+	}
+
+	maxFileSize, err := cmd.Flags().GetInt64("max-file-size")
+	if err != nil {
+		log.Fatalf("can not get 'max-file-size' value, %v", err)
+	}
+
+	fromAddress, err := cmd.Flags().GetString("from-address")
+	if err != nil {
+		log.Fatalf("can not get 'from-address' value, %v", err)
+	}
+
+	fromUsername, err := cmd.Flags().GetString("from-username")
+	if err != nil {
+		log.Fatalf("can not get 'from-username' value, %v", err)
+	}
+
+	fromPassword, err := cmd.Flags().GetString("from-password")
+	if err != nil {
+		log.Fatalf("can not get 'from-password' value, %v", err)
+	}
+
+	logFromRequests, err := cmd.Flags().GetBool("log-from-requests")
+	if err != nil {
+		log.Fatalf("can not get 'log-from-requests' value, %v", err)
+	}
+
+	logFromResponses, err := cmd.Flags().GetBool("log-from-responses")
+	if err != nil {
+		log.Fatalf("can not get 'log-from-responses' value, %v", err)
+	}
+
+	e, err := syncer.NewExporter(syncer.ExportConfig{
+		Since:            since,
+		Limit:            limit,
+		Index:            index,
+		TimeField:        timeField,
+		Slices:           slices,
+		PageSize:         pageSize,
+		ReadMode:         readMode,
+		Query:            queryDSL,
+		QueryString:      queryString,
+		SourceIncludes:   sourceIncludes,
+		SourceExcludes:   sourceExcludes,
+		Dir:              dir,
+		Gzip:             gzip,
+		MaxFileSize:      maxFileSize,
+		FromHost:         fromAddress,
+		FromUsername:     fromUsername,
+		FromPassword:     fromPassword,
+		LogFromRequests:  logFromRequests,
+		LogFromResponses: logFromResponses,
+		Logger:           logger,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	manifest, err := e.Export(ctx)
+	if err != nil {
+		log.Fatalf("export failed, %s", err.Error())
+	}
+
+	logger.Infof("exported %d indices to '%s'", len(manifest.Indices), dir)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/rkspx/elastic-syncer/syncer"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import [path...]",
+	Short: "load export directories, or elasticdump and bulk NDJSON files, into elasticsearch",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   importPaths,
+}
+
+func init() {
+	importCmd.Flags().String("index", "", "index documents are loaded into instead of their own index, required if documents have no index")
+	importCmd.Flags().StringArray("rename", nil, "rule renaming indices, either 'regex=replacement' with capture groups as '$1', 'prefix:old=new' or 'suffix:old=new', can be repeated, the first matching rule applies")
+	importCmd.Flags().Int("workers", syncer.DefaultImportWorkers, "number of files read concurrently, default: 4")
+	importCmd.Flags().String("time-field", syncer.DefaultTimeField, "time field compared by the 'newer-wins' conflict policy, detected from the index mappings of export directories if empty")
+	importCmd.Flags().Bool("external-version", false, "index documents with their version as external version, so newer documents are never overwritten")
+	importCmd.Flags().String("on-conflict", syncer.DefaultOnConflict, "what happens to documents already existing, one of 'create' keeping them, 'overwrite', 'newer-wins' keeping them if their time field or version is newer, or 'merge' keeping their fields missing on the imported documents, default: overwrite")
+	importCmd.Flags().String("to-address", "", "destination elasticsearch address")
+	importCmd.Flags().String("to-username", "", "destination elasticsearch username, if using basic authentication")
+	importCmd.Flags().String("to-password", "", "destination elasticsearch password, if using basic authentication")
+	importCmd.Flags().Bool("log-to-requests", false, "log destination elasticsearch requests")
+	importCmd.Flags().Bool("log-to-responses", false, "log destination elasticsearch requests")
+
+	rootCmd.AddCommand(importCmd)
+}
+
+func importPaths(cmd *cobra.Command, args []string) {
+	index, err := cmd.Flags().GetString("index")
+	if err != nil {
+		log.Fatalf("can not get 'index' value, %v", err)
+	}
+
+	rename, err := cmd.Flags().GetStringArray("rename")
+	if err != nil {
+		log.Fatalf("can not get 'rename' value, %v", err)
+	}
+
+	workers, err := cmd.Flags().GetInt("workers")
+	if err != nil {
+		log.Fatalf("can not get 'workers' value, %v", err)
+	}
+
+	timeField, err := cmd.Flags().GetString("time-field")
+	if err != nil {
+		log.Fatalf("can not get 'time-field' value, %v", err)
+	}
+
+	externalVersion, err := cmd.Flags().GetBool("external-version")
+	if err != nil {
+		log.Fatalf("can not get 'external-version' value, %v", err)
+	}
+
+	onConflict, err := cmd.Flags().GetString("on-conflict")
+	if err != nil {
+		log.Fatalf("can not get 'on-conflict' value, %v", err)
+	}
+
+	toAddress, err := cmd.Flags().GetString("to-address")
+	if err != nil {
+		log.Fatalf("can not get 'to-address' value, %v", err)
+	}
+
+	toUsername, err := cmd.Flags().GetString("to-username")
+	if err != nil {
+		log.Fatalf("can not get 'to-username' value, %v", err)
+	}
+
+	toPassword, err := cmd.Flags().GetString("to-password")
+	if err != nil {
+		log.Fatalf("can not get 'to-password' value, %v", err)
+	}
+
+	logToRequests, err := cmd.Flags().GetBool("log-to-requests")
+	if err != nil {
+		log.Fatalf("can not get 'log-to-requests' value, %v", err)
+	}
+
+	logToResponses, err := cmd.Flags().GetBool("log-to-responses")
+	if err != nil {
+		log.Fatalf("can not get 'log-to-responses' value, %v", err)
+	}
+
+	i, err := syncer.NewImporter(syncer.ImportConfig{
+		Paths:           args,
+		Index:           index,
+		Rename:          rename,
+		Workers:         workers,
+		TimeField:       timeField,
+		ExternalVersion: externalVersion,
+		OnConflict:      onConflict,
+		ToHost:          toAddress,
+		ToUsername:      toUsername,
+		ToPassword:      toPassword,
+		LogToRequests:   logToRequests,
+		LogToResponses:  logToResponses,
+		Logger:          logger,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	result, err := i.Import(ctx)
+	if err != nil {
+		log.Fatalf("import failed, %s", err.Error())
+	}
+
+	if result.Failed > 0 {
+		log.Fatalf("imported %d documents, %d documents failed", result.Documents, result.Failed)
+	}
+
+	logger.Infof("imported %d documents", result.Documents)
+}
@@ -2,20 +2,64 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"os"
 
+	"github.com/rkspx/elastic-syncer/syncer"
 	"github.com/spf13/cobra"
 )
 
 var buildtime, version string
 
+// logger is the logger of every command, set up from the log flags before running the command.
+var logger syncer.Logger
+
+// terminal is stderr if it's a terminal, the logger writes through it so the sync progress line
+// is redrawn below log entries.
+var terminal *syncer.Terminal
+
 var rootCmd = &cobra.Command{
-	Short:   "elasticsearch sync utility",
-	Version: fmt.Sprintf("ver %s, build-time %s", version, buildtime),
+	Short:            "elasticsearch sync utility",
+	Version:          fmt.Sprintf("ver %s, build-time %s", version, buildtime),
+	PersistentPreRun: setupLogger,
 }
 
 func init() {
+	rootCmd.PersistentFlags().String("log-format", syncer.LogFormatText, "log format, either 'text' or 'json'")
+	rootCmd.PersistentFlags().String("log-level", "info", "minimum level of logs, either 'debug', 'info', 'warn' or 'error', documents read and written are logged at 'debug' level")
+}
+
+// setupLogger creates the logger from the log flags, and routes the standard logger through it at
+// error level.
+func setupLogger(cmd *cobra.Command, args []string) {
+	format, err := cmd.Flags().GetString("log-format")
+	if err != nil {
+		log.Fatalf("can not get 'log-format' value, %v", err)
+	}
+
+	levelName, err := cmd.Flags().GetString("log-level")
+	if err != nil {
+		log.Fatalf("can not get 'log-level' value, %v", err)
+	}
+
+	level, err := syncer.ParseLevel(levelName)
+	if err != nil {
+		log.Fatalf("invalid 'log-level' value '%s', %s", levelName, err.Error())
+	}
+
+	var out io.Writer = os.Stderr
+	if terminal = syncer.NewTerminal(os.Stderr); terminal != nil {
+		out = terminal
+	}
+
+	logger, err = syncer.NewLogger(out, format, level)
+	if err != nil {
+		log.Fatalf("invalid 'log-format' value '%s', %s", format, err.Error())
+	}
 
+	log.SetFlags(0)
+	log.SetOutput(syncer.NewLogWriter(logger, syncer.LevelError))
 }
 
 func main() {
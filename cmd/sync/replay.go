@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/rkspx/elastic-syncer/syncer"
+	"github.com/spf13/cobra"
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay-failed",
+	Short: "write the documents of a dead-letter file to elasticsearch again, keeping the documents failing again in the file",
+	Run:   replay,
+}
+
+func init() {
+	replayCmd.Flags().String("dead-letter-file", syncer.DefaultDeadLetterFile, "dead-letter file written by 'sync'")
+	replayCmd.Flags().Bool("external-version", false, "index documents with their source version as external version, so newer destination documents are never overwritten")
+	replayCmd.Flags().String("on-conflict", syncer.DefaultOnConflict, "what happens to documents already existing on the destination, one of 'create' keeping them, 'overwrite', 'newer-wins' keeping them if their time field or version is newer, or 'merge' keeping their fields missing on the source, default: overwrite")
+	replayCmd.Flags().String("to-address", "", "destination elasticsearch address")
+	replayCmd.Flags().String("to-username", "", "destination elasticsearch username, if using basic authentication")
+	replayCmd.Flags().String("to-password", "", "destination elasticsearch password, if using basic authentication")
+	replayCmd.Flags().Bool("log-to-requests", false, "log destination elasticsearch requests")
+	replayCmd.Flags().Bool("log-to-responses", false, "log destination elasticsearch requests")
+
+	rootCmd.AddCommand(replayCmd)
+}
+
+func replay(cmd *cobra.Command, args []string) {
+	deadLetterFile, err := cmd.Flags().GetString("dead-letter-file")
+	if err != nil {
+		log.Fatalf("can not get 'dead-letter-file' value, %v", err)
+	}
+
+	externalVersion, err := cmd.Flags().GetBool("external-version")
+	if err != nil {
+		log.Fatalf("can not get 'external-version' value, %v", err)
+	}
+
+	onConflict, err := cmd.Flags().GetString("on-conflict")
+	if err != nil {
+		log.Fatalf("can not get 'on-conflict' value, %v", err)
+	}
+
+	toAddress, err := cmd.Flags().GetString("to-address")
+	if err != nil {
+		log.Fatalf("can not get 'to-address' value, %v", err)
+	}
+
+	toUsername, err := cmd.Flags().GetString("to-username")
+	if err != nil {
+		log.Fatalf("can not get 'to-username' value, %v", err)
+	}
+
+	toPassword, err := cmd.Flags().GetString("to-password")
+	if err != nil {
+		log.Fatalf("can not get 'to-password' value, %v", err)
+	}
+
+	logToRequests, err := cmd.Flags().GetBool("log-to-requests")
+	if err != nil {
+		log.Fatalf("can not get 'log-to-requests' value, %v", err)
+	}
+
+	logToResponses, err := cmd.Flags().GetBool("log-to-responses")
+	if err != nil {
+		log.Fatalf("can not get 'log-to-responses' value, %v", err)
+	}
+
+	r, err := syncer.NewReplayer(syncer.ReplayConfig{
+		File:            deadLetterFile,
+		ExternalVersion: externalVersion,
+		OnConflict:      onConflict,
+		ToHost:          toAddress,
+		ToUsername:      toUsername,
+		ToPassword:      toPassword,
+		LogToRequests:   logToRequests,
+		LogToResponses:  logToResponses,
+		Logger:          logger,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	result, err := r.Replay(ctx)
+	if err != nil {
+		log.Fatalf("replay failed, %s", err.Error())
+	}
+
+	if result.Failed > 0 {
+		log.Fatalf("replayed %d documents, %d documents failed again and are kept in '%s'", result.Written, result.Failed, deadLetterFile)
+	}
+
+	logger.Infof("replayed %d documents", result.Written)
+}
@@ -2,24 +2,76 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 
 	"github.com/rkspx/elastic-syncer/syncer"
 	"github.com/spf13/cobra"
 )
 
+const (
+	planFormatText = "text"
+	planFormatJSON = "json"
+)
+
+// exit codes of the sync command, a sync failing on any other error exits with status 1.
+const (
+	exitPartialFailure = 2
+	exitTotalFailure   = 3
+	exitCancelled      = 130
+)
+
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "copy documents from one elasticsearch server to another",
-	Run:   sync,
+	Long: `copy documents from one elasticsearch server to another
+
+Exit codes:
+  0    every index is synced
+  1    the sync failed on error
+  2    more documents than allowed by --fail-on-errors failed to be written
+  3    every document failed to be written
+  130  the sync is cancelled`,
+	Run: sync,
 }
 
 func init() {
-	syncCmd.Flags().Duration("since", syncer.DefaultSince, "copy all documents that dated since the specified duration, only works if the document has 'timesetamp' field with 'date' field type, default: 30d")
+	syncCmd.Flags().Duration("since", syncer.DefaultSince, "copy all documents that dated since the specified duration, only works if the index has a time field with 'date' or 'date_nanos' field type, default: 30d")
 	syncCmd.Flags().Int("limit", syncer.DefaultLimit, "limit number of synced document, set to 0 to disable, default: 0")
 	syncCmd.Flags().String("index", syncer.DefaultIndex, "index name")
+	syncCmd.Flags().String("time-field", syncer.DefaultTimeField, "time field used to sort and filter documents, use dotted path for nested fields, detected from the index mappings if empty")
+	syncCmd.Flags().String("state-file", "", "file where the checkpoint of every synced index is persisted, checkpoints are disabled if empty unless resuming")
+	syncCmd.Flags().Bool("resume", false, "continue reading from the checkpoints persisted in the state file, skipping completely synced indices, the state file defaults to '"+syncer.DefaultStateFile+"'")
+	syncCmd.Flags().Bool("follow", false, "keep polling indices for new documents after the initial sync, until interrupted")
+	syncCmd.Flags().Duration("follow-interval", syncer.DefaultFollowInterval, "interval between polls in follow mode, default: 1m")
+	syncCmd.Flags().Int("slices", syncer.DefaultSlices, "number of slices each point-in-time is split into and read concurrently, default: 1")
+	syncCmd.Flags().Int("page-size", syncer.DefaultPageSize, "number of documents read per search request, default: 1000")
+	syncCmd.Flags().String("query", "", "query DSL selecting the documents to sync, either inline JSON or '@' followed by a file path")
+	syncCmd.Flags().String("q", "", "lucene query string selecting the documents to sync")
+	syncCmd.Flags().StringSlice("source-includes", nil, "comma separated document fields to copy, wildcards are supported, all fields are copied if empty")
+	syncCmd.Flags().StringSlice("source-excludes", nil, "comma separated document fields to not copy, wildcards are supported")
+	syncCmd.Flags().Bool("drop-excluded-mappings", false, "drop the excluded document fields from the mappings of created destination indices")
+	syncCmd.Flags().StringSlice("settings-allow", nil, "comma separated index settings to copy to created destination indices, e.g. 'index.routing.allocation.require', the most specific setting wins")
+	syncCmd.Flags().StringSlice("settings-deny", nil, "comma separated index settings to not copy to created destination indices, in addition to settings specific to the source cluster")
+	syncCmd.Flags().Bool("reconcile-mappings", false, "add fields missing on existing destination indices mappings, conflicting field types abort the sync either way")
+	syncCmd.Flags().StringArray("rename", nil, "rule renaming source indices to destination indices, either 'regex=replacement' with capture groups as '$1', e.g. 'logs-prod-(.*)=logs-staging-$1', 'prefix:old=new' or 'suffix:old=new', can be repeated, the first matching rule applies")
+	syncCmd.Flags().String("transform-file", "", "JSON file configuring the transforms applied to documents before they're written, e.g. '{\"transforms\": [{\"type\": \"drop\", \"fields\": [\"password\"]}]}'")
+	syncCmd.Flags().Bool("external-version", false, "index documents with their source version as external version, so newer destination documents are never overwritten")
+	syncCmd.Flags().String("on-conflict", syncer.DefaultOnConflict, "what happens to documents already existing on the destination, one of 'create' keeping them, 'overwrite', 'newer-wins' keeping them if their time field or version is newer, or 'merge' keeping their fields missing on the source, default: overwrite")
+	syncCmd.Flags().Bool("mirror-deletes", false, "delete destination documents within the sync window which don't exist on the source anymore")
+	syncCmd.Flags().Float64("mirror-deletes-threshold", syncer.DefaultMirrorDeletesThreshold, "abort instead of mirroring deletes if more than this percentage of destination documents would be deleted, default: 10")
+	syncCmd.Flags().String("dead-letter-file", syncer.DefaultDeadLetterFile, "NDJSON file where documents failed to be written are appended, to be replayed with 'replay-failed', set to empty to disable it")
+	syncCmd.Flags().Float64("fail-on-errors", syncer.DefaultFailOnErrors, "percentage of read documents which can fail to be written, or mirrored deletes which can fail, before the sync fails, exiting with status 2, or 3 if every document failed, default: 0")
+	syncCmd.Flags().Duration("progress-interval", syncer.DefaultProgressInterval, "interval between progress log entries, on a terminal the progress is a single line updated every second, set to 0 to disable it")
+	syncCmd.Flags().String("metrics-addr", "", "address to serve Prometheus metrics on at '/metrics', e.g. ':9090', disabled if empty")
+	syncCmd.Flags().String("read-mode", syncer.DefaultReadMode, "how documents are read from the source, one of 'auto', 'pit', 'scroll' or 'paginate', default: auto")
+	syncCmd.Flags().Bool("dry-run", false, "print which indices would be created or skipped, how they would be read and an estimate of the documents to sync, without writing anything")
+	syncCmd.Flags().String("plan-format", planFormatText, "format of the dry run plan, either 'text' or 'json', default: text")
 	syncCmd.Flags().String("from-address", "", "source elasticsearch address")
 	syncCmd.Flags().String("from-username", "", "source elasticsearch username, if using basic authentication")
 	syncCmd.Flags().String("from-password", "", "source elasticsearch password, if using basic authentication")
@@ -50,6 +102,160 @@ func sync(cmd *cobra.Command, args []string) {
 		log.Fatalf("can not get 'index' value, %v", err)
 	}
 
+	timeField, err := cmd.Flags().GetString("time-field")
+	if err != nil {
+		log.Fatalf("can not get 'time-field' value, %v", err)
+	}
+
+	stateFile, err := cmd.Flags().GetString("state-file")
+	if err != nil {
+		log.Fatalf("can not get 'state-file' value, %v", err)
+	}
+
+	resume, err := cmd.Flags().GetBool("resume")
+	if err != nil {
+		log.Fatalf("can not get 'resume' value, %v", err)
+	}
+
+	follow, err := cmd.Flags().GetBool("follow")
+	if err != nil {
+		log.Fatalf("can not get 'follow' value, %v", err)
+	}
+
+	followInterval, err := cmd.Flags().GetDuration("follow-interval")
+	if err != nil {
+		log.Fatalf("can not get 'follow-interval' value, %v", err)
+	}
+
+	slices, err := cmd.Flags().GetInt("slices")
+	if err != nil {
+		log.Fatalf("can not get 'slices' value, %v", err)
+	}
+
+	pageSize, err := cmd.Flags().GetInt("page-size")
+	if err != nil {
+		log.Fatalf("can not get 'page-size' value, %v", err)
+	}
+
+	query, err := cmd.Flags().GetString("query")
+	if err != nil {
+		log.Fatalf("can not get 'query' value, %v", err)
+	}
+
+	queryDSL, err := readQuery(query)
+	if err != nil {
+		log.Fatalf("can not read 'query' value, %v", err)
+	}
+
+	queryString, err := cmd.Flags().GetString("q")
+	if err != nil {
+		log.Fatalf("can not get 'q' value, %v", err)
+	}
+
+	sourceIncludes, err := cmd.Flags().GetStringSlice("source-includes")
+	if err != nil {
+		log.Fatalf("can not get 'source-includes' value, %v", err)
+	}
+
+	sourceExcludes, err := cmd.Flags().GetStringSlice("source-excludes")
+	if err != nil {
+		log.Fatalf("can not get 'source-excludes' value, %v", err)
+	}
+
+	dropExcludedMappings, err := cmd.Flags().GetBool("drop-excluded-mappings")
+	if err != nil {
+		log.Fatalf("can not get 'drop-excluded-mappings' value, %v", err)
+	}
+
+	settingsAllow, err := cmd.Flags().GetStringSlice("settings-allow")
+	if err != nil {
+		log.Fatalf("can not get 'settings-allow' value, %v", err)
+	}
+
+	settingsDeny, err := cmd.Flags().GetStringSlice("settings-deny")
+	if err != nil {
+		log.Fatalf("can not get 'settings-deny' value, %v", err)
+	}
+
+	reconcileMappings, err := cmd.Flags().GetBool("reconcile-mappings")
+	if err != nil {
+		log.Fatalf("can not get 'reconcile-mappings' value, %v", err)
+	}
+
+	rename, err := cmd.Flags().GetStringArray("rename")
+	if err != nil {
+		log.Fatalf("can not get 'rename' value, %v", err)
+	}
+
+	transformFile, err := cmd.Flags().GetString("transform-file")
+	if err != nil {
+		log.Fatalf("can not get 'transform-file' value, %v", err)
+	}
+
+	externalVersion, err := cmd.Flags().GetBool("external-version")
+	if err != nil {
+		log.Fatalf("can not get 'external-version' value, %v", err)
+	}
+
+	onConflict, err := cmd.Flags().GetString("on-conflict")
+	if err != nil {
+		log.Fatalf("can not get 'on-conflict' value, %v", err)
+	}
+
+	mirrorDeletes, err := cmd.Flags().GetBool("mirror-deletes")
+	if err != nil {
+		log.Fatalf("can not get 'mirror-deletes' value, %v", err)
+	}
+
+	mirrorDeletesThreshold, err := cmd.Flags().GetFloat64("mirror-deletes-threshold")
+	if err != nil {
+		log.Fatalf("can not get 'mirror-deletes-threshold' value, %v", err)
+	}
+
+	readMode, err := cmd.Flags().GetString("read-mode")
+	if err != nil {
+		log.Fatalf("can not get 'read-mode' value, %v", err)
+	}
+
+	deadLetterFile, err := cmd.Flags().GetString("dead-letter-file")
+	if err != nil {
+		log.Fatalf("can not get 'dead-letter-file' value, %v", err)
+	}
+
+	failOnErrors, err := cmd.Flags().GetFloat64("fail-on-errors")
+	if err != nil {
+		log.Fatalf("can not get 'fail-on-errors' value, %v", err)
+	}
+
+	progressInterval, err := cmd.Flags().GetDuration("progress-interval")
+	if err != nil {
+		log.Fatalf("can not get 'progress-interval' value, %v", err)
+	}
+
+	metricsAddr, err := cmd.Flags().GetString("metrics-addr")
+	if err != nil {
+		log.Fatalf("can not get 'metrics-addr' value, %v", err)
+	}
+
+	var metrics *syncer.Metrics
+	if metricsAddr != "" {
+		metrics = syncer.NewMetrics()
+	}
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		log.Fatalf("can not get 'dry-run' value, %v", err)
+	}
+
+	planFormat, err := cmd.Flags().GetString("plan-format")
+	if err != nil {
+		log.Fatalf("can not get 'plan-format' value, %v", err)
+	}
+
+	if planFormat != planFormatText && planFormat != planFormatJSON {
+		log.Fatalf("unknown 'plan-format' value '%s', expecting either 'text' or 'json'", planFormat)
+	}
+
 	fromAddress, err := cmd.Flags().GetString("from-address")
 	if err != nil {
 		log.Fatalf("can not get 'from-address' value, %v", err)
@@ -101,19 +307,47 @@ func sync(cmd *cobra.Command, args []string) {
 	}
 
 	cl, err := syncer.New(syncer.Config{
-		Since:            since,
-		Limit:            limit,
-		Index:            index,
-		FromHost:         fromAddress,
-		FromUsername:     fromUsername,
-		FromPassword:     fromPassword,
-		LogFromRequests:  logFromRequests,
-		LogFromResponses: logFromResponses,
-		ToHost:           toAddress,
-		ToUsername:       toUsername,
-		ToPassword:       toPassword,
-		LogToRequests:    logToRequests,
-		LogToResponses:   logToResponses,
+		Since:                  since,
+		Limit:                  limit,
+		Index:                  index,
+		TimeField:              timeField,
+		StateFile:              stateFile,
+		Resume:                 resume,
+		Follow:                 follow,
+		FollowInterval:         followInterval,
+		Slices:                 slices,
+		PageSize:               pageSize,
+		ReadMode:               readMode,
+		Query:                  queryDSL,
+		QueryString:            queryString,
+		SourceIncludes:         sourceIncludes,
+		SourceExcludes:         sourceExcludes,
+		DropExcludedMappings:   dropExcludedMappings,
+		SettingsAllow:          settingsAllow,
+		SettingsDeny:           settingsDeny,
+		ReconcileMappings:      reconcileMappings,
+		Rename:                 rename,
+		TransformFile:          transformFile,
+		ExternalVersion:        externalVersion,
+		OnConflict:             onConflict,
+		MirrorDeletes:          mirrorDeletes,
+		MirrorDeletesThreshold: &mirrorDeletesThreshold,
+		DeadLetterFile:         deadLetterFile,
+		FailOnErrors:           failOnErrors,
+		ProgressInterval:       progressInterval,
+		Logger:                 logger,
+		Terminal:               terminal,
+		Metrics:                metrics,
+		FromHost:               fromAddress,
+		FromUsername:           fromUsername,
+		FromPassword:           fromPassword,
+		LogFromRequests:        logFromRequests,
+		LogFromResponses:       logFromResponses,
+		ToHost:                 toAddress,
+		ToUsername:             toUsername,
+		ToPassword:             toPassword,
+		LogToRequests:          logToRequests,
+		LogToResponses:         logToResponses,
 	})
 
 	if err != nil {
@@ -123,7 +357,74 @@ func sync(cmd *cobra.Command, args []string) {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	if err := cl.Sync(ctx); err != nil {
+	if dryRun {
+		if err := printPlan(ctx, cl, planFormat); err != nil {
+			log.Fatalf("dry run failed, %s", err.Error())
+		}
+
+		return
+	}
+
+	// metrics are served only when syncing, a dry run doesn't bind the metrics address.
+	if metrics != nil {
+		serveMetrics(metricsAddr, metrics)
+	}
+
+	result, err := cl.Sync(ctx)
+	if len(result.Indices) > 0 {
+		if err := result.WriteTable(os.Stdout); err != nil {
+			logger.Errorf("can not write result, %s", err.Error())
+		}
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		logger.Warnf("sync cancelled")
+		os.Exit(exitCancelled)
+	case errors.Is(err, syncer.ErrPartialFailure):
+		logger.Errorf("sync partially failed, %s", err.Error())
+		os.Exit(exitPartialFailure)
+	case errors.Is(err, syncer.ErrTotalFailure):
+		logger.Errorf("sync failed, %s", err.Error())
+		os.Exit(exitTotalFailure)
+	case err != nil:
 		log.Fatalf("sync failed, %s", err.Error())
 	}
 }
+
+// readQuery returns the query DSL specified inline, or read from the file if it's prefixed with '@'.
+func readQuery(value string) (json.RawMessage, error) {
+	if !strings.HasPrefix(value, "@") {
+		return json.RawMessage(value), nil
+	}
+
+	return os.ReadFile(strings.TrimPrefix(value, "@"))
+}
+
+// printPlan prints what the sync would do to stdout, in the format.
+func printPlan(ctx context.Context, cl *syncer.Client, format string) error {
+	plan, err := cl.Plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	if format == planFormatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
+	}
+
+	return plan.WriteText(os.Stdout)
+}
+
+// serveMetrics serves the metrics in the background, the server stops with the process.
+func serveMetrics(addr string, metrics *syncer.Metrics) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	go func() {
+		logger.Infof("serving metrics on '%s'", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logger.Errorf("failed to serve metrics, %s", err.Error())
+		}
+	}()
+}
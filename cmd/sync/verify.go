@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/rkspx/elastic-syncer/syncer"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "compare documents of source elasticsearch indices with their copy on destination elasticsearch, exits with non-zero status if they differ",
+	Run:   verify,
+}
+
+func init() {
+	verifyCmd.Flags().Duration("since", syncer.DefaultSince, "compare all documents that dated since the specified duration, only works if the index has a time field with 'date' or 'date_nanos' field type, default: 30d")
+	verifyCmd.Flags().String("index", syncer.DefaultIndex, "index name")
+	verifyCmd.Flags().String("time-field", syncer.DefaultTimeField, "time field used to filter documents and bucket document counts, use dotted path for nested fields, detected from the index mappings if empty")
+	verifyCmd.Flags().String("query", "", "query DSL selecting the documents to compare, either inline JSON or '@' followed by a file path")
+	verifyCmd.Flags().String("q", "", "lucene query string selecting the documents to compare")
+	verifyCmd.Flags().StringArray("rename", nil, "rule renaming source indices to destination indices, as used to sync, can be repeated")
+	verifyCmd.Flags().String("interval", syncer.DefaultVerifyInterval, "interval of the time buckets whose document counts are compared, e.g. '1h', default: 1d")
+	verifyCmd.Flags().Bool("hashes", false, "compare the '_source' hash of every document, documents transformed by the sync differ")
+	verifyCmd.Flags().Int("page-size", syncer.DefaultPageSize, "number of documents read per search request when comparing hashes, default: 1000")
+	verifyCmd.Flags().String("from-address", "", "source elasticsearch address")
+	verifyCmd.Flags().String("from-username", "", "source elasticsearch username, if using basic authentication")
+	verifyCmd.Flags().String("from-password", "", "source elasticsearch password, if using basic authentication")
+	verifyCmd.Flags().Bool("log-from-requests", false, "log source elasticsearch requests")
+	verifyCmd.Flags().Bool("log-from-responses", false, "log source elasticsearch requests")
+	verifyCmd.Flags().String("to-address", "", "destination elasticsearch address")
+	verifyCmd.Flags().String("to-username", "", "destination elasticsearch username, if using basic authentication")
+	verifyCmd.Flags().String("to-password", "", "destination elasticsearch password, if using basic authentication")
+	verifyCmd.Flags().Bool("log-to-requests", false, "log destination elasticsearch requests")
+	verifyCmd.Flags().Bool("log-to-responses", false, "log destination elasticsearch requests")
+
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func verify(cmd *cobra.Command, args []string) {
+	since, err := cmd.Flags().GetDuration("since")
+	if err != nil {
+		log.Fatalf("can not get 'since' value, %v", err)
+	}
+
+	index, err := cmd.Flags().GetString("index")
+	if err != nil {
+		log.Fatalf("can not get 'index' value, %v", err)
+	}
+
+	timeField, err := cmd.Flags().GetString("time-field")
+	if err != nil {
+		log.Fatalf("can not get 'time-field' value, %v", err)
+	}
+
+	query, err := cmd.Flags().GetString("query")
+	if err != nil {
+		log.Fatalf("can not get 'query' value, %v", err)
+	}
+
+	queryDSL, err := readQuery(query)
+	if err != nil {
+		log.Fatalf("can not read 'query' value, %v", err)
+	}
+
+	queryString, err := cmd.Flags().GetString("q")
+	if err != nil {
+		log.Fatalf("can not get 'q' value, %v", err)
+	}
+
+	rename, err := cmd.Flags().GetStringArray("rename")
+	if err != nil {
+		log.Fatalf("can not get 'rename' value, %v", err)
+	}
+
+	interval, err := cmd.Flags().GetString("interval")
+	if err != nil {
+		log.Fatalf("can not get 'interval' value, %v", err)
+	}
+
+	hashes, err := cmd.Flags().GetBool("hashes")
+	if err != nil {
+		log.Fatalf("can not get 'hashes' value, %v", err)
+	}
+
+	pageSize, err := cmd.Flags().GetInt("page-size")
+	if err != nil {
+		log.Fatalf("can not get 'page-size' value, %v", err)
+	}
+
+	fromAddress, err := cmd.Flags().GetString("from-address")
+	if err != nil {
+		log.Fatalf("can not get 'from-address' value, %v", err)
+	}
+
+	fromUsername, err := cmd.Flags().GetString("from-username")
+	if err != nil {
+		log.Fatalf("can not get 'from-username' value, %v", err)
+	}
+
+	fromPassword, err := cmd.Flags().GetString("from-password")
+	if err != nil {
+		log.Fatalf("can not get 'from-password' value, %v", err)
+	}
+
+	logFromRequests, err := cmd.Flags().GetBool("log-from-requests")
+	if err != nil {
+		log.Fatalf("can not get 'log-from-requests' value, %v", err)
+	}
+
+	logFromResponses, err := cmd.Flags().GetBool("log-from-responses")
+	if err != nil {
+		log.Fatalf("can not get 'log-from-responses' value, %v", err)
+	}
+
+	toAddress, err := cmd.Flags().GetString("to-address")
+	if err != nil {
+		log.Fatalf("can not get 'to-address' value, %v", err)
+	}
+
+	toUsername, err := cmd.Flags().GetString("to-username")
+	if err != nil {
+		log.Fatalf("can not get 'to-username' value, %v", err)
+	}
+
+	toPassword, err := cmd.Flags().GetString("to-password")
+	if err != nil {
+		log.Fatalf("can not get 'to-password' value, %v", err)
+	}
+
+	logToRequests, err := cmd.Flags().GetBool("log-to-requests")
+	if err != nil {
+		log.Fatalf("can not get 'log-to-requests' value, %v", err)
+	}
+
+	logToResponses, err := cmd.Flags().GetBool("log-to-responses")
+	if err != nil {
+		log.Fatalf("can not get 'log-to-responses' value, %v", err)
+	}
+
+	v, err := syncer.NewVerifier(syncer.VerifyConfig{
+		Since:            since,
+		Index:            index,
+		TimeField:        timeField,
+		Query:            queryDSL,
+		QueryString:      queryString,
+		Rename:           rename,
+		Interval:         interval,
+		Hashes:           hashes,
+		PageSize:         pageSize,
+		FromHost:         fromAddress,
+		FromUsername:     fromUsername,
+		FromPassword:     fromPassword,
+		LogFromRequests:  logFromRequests,
+		LogFromResponses: logFromResponses,
+		ToHost:           toAddress,
+		ToUsername:       toUsername,
+		ToPassword:       toPassword,
+		LogToRequests:    logToRequests,
+		LogToResponses:   logToResponses,
+		Logger:           logger,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	report, err := v.Verify(ctx)
+	if err != nil {
+		log.Fatalf("verify failed, %s", err.Error())
+	}
+
+	if !report.Diverged() {
+		logger.Infof("%d indices are identical on source and destination elasticsearch", report.Indices)
+		return
+	}
+
+	if err := report.WriteTable(os.Stdout); err != nil {
+		log.Fatalf("can not write report, %s", err.Error())
+	}
+
+	log.Fatalf("%d differences found between source and destination elasticsearch", len(report.Mismatches))
+}
@@ -0,0 +1,25 @@
+package esutil
+
+import (
+	"encoding/json"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+type CountResponse struct {
+	Count int64 `json:"count"`
+}
+
+func ParseCountResponse(res *esapi.Response) (int64, error) {
+	defer res.Body.Close()
+	if res.IsError() {
+		return 0, ParseCommonError(res.Body)
+	}
+
+	var resp CountResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, err
+	}
+
+	return resp.Count, nil
+}
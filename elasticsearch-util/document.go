@@ -25,9 +25,15 @@ func (d Document) ToReader() (io.Reader, error) {
 	return bytes.NewReader(b), nil
 }
 
+// DocumentMetadata is the document metadata returned by searches. Version, SeqNo and PrimaryTerm
+// are only set if requested with the `version` and `seq_no_primary_term` search parameters.
 type DocumentMetadata struct {
-	Index string `json:"_index"`
-	ID    string `json:"_id"`
+	Index       string `json:"_index"`
+	ID          string `json:"_id"`
+	Routing     string `json:"_routing,omitempty"`
+	Version     *int64 `json:"_version,omitempty"`
+	SeqNo       *int64 `json:"_seq_no,omitempty"`
+	PrimaryTerm *int64 `json:"_primary_term,omitempty"`
 }
 
 type SortMetadata struct {
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"path"
+	"sort"
+	"strings"
 
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 )
@@ -18,11 +21,11 @@ func (i IndexSetting) Parse() ([]byte, error) {
 }
 
 func (i IndexSetting) Replicas() int {
-	return stringToInt(i.Setting.Settings.Index.NumberOfReplicas)
+	return stringToInt(i.Setting.Settings.Get("index.number_of_replicas"))
 }
 
 func (i IndexSetting) Shards() int {
-	return stringToInt(i.Setting.Settings.Index.NumberOfShards)
+	return stringToInt(i.Setting.Settings.Get("index.number_of_shards"))
 }
 
 type SettingInner struct {
@@ -33,28 +36,289 @@ type SettingInner struct {
 
 type Aliases = map[string]any
 
+// Mappings is the index mappings. Properties are typed for the syncer to inspect, every other
+// mapping parameter, e.g. `dynamic`, `dynamic_templates`, `_source`, `_routing` or `runtime`, is
+// kept verbatim in Extra, so the mappings round-trip without losing anything.
 type Mappings struct {
 	Properties map[string]MappingProperty `json:"properties,omitempty"`
+	Extra      map[string]json.RawMessage `json:"-"`
 }
 
+type mappings Mappings
+
+func (m *Mappings) UnmarshalJSON(b []byte) error {
+	return unmarshalWithExtra(b, (*mappings)(m), &m.Extra, "properties")
+}
+
+func (m Mappings) MarshalJSON() ([]byte, error) {
+	return marshalWithExtra(mappings(m), m.Extra)
+}
+
+// Param returns the raw value of a mapping parameter other than `properties`, e.g. `dynamic`.
+func (m Mappings) Param(name string) (json.RawMessage, bool) {
+	v, ok := m.Extra[name]
+	return v, ok
+}
+
+// MappingProperty is a field mapping. Type, multi-fields and object properties are typed, every
+// other mapping parameter, e.g. `analyzer`, `format`, `index`, `copy_to` or `dims`, is kept
+// verbatim in Extra.
 type MappingProperty struct {
 	Type       string                              `json:"type,omitempty"`
 	Fields     map[string]MappingPropertyFieldType `json:"fields,omitempty"`
 	Properties map[string]MappingProperty          `json:"properties,omitempty"`
+	Extra      map[string]json.RawMessage          `json:"-"`
+}
+
+type mappingProperty MappingProperty
+
+func (p *MappingProperty) UnmarshalJSON(b []byte) error {
+	return unmarshalWithExtra(b, (*mappingProperty)(p), &p.Extra, "type", "fields", "properties")
+}
+
+func (p MappingProperty) MarshalJSON() ([]byte, error) {
+	return marshalWithExtra(mappingProperty(p), p.Extra)
+}
+
+// Param returns the raw value of a mapping parameter other than `type`, `fields` and
+// `properties`, e.g. `format`.
+func (p MappingProperty) Param(name string) (json.RawMessage, bool) {
+	v, ok := p.Extra[name]
+	return v, ok
+}
+
+// DateFields returns the dotted paths of every `date` and `date_nanos` field in the mappings,
+// including fields inside object properties, sorted by path.
+func (m Mappings) DateFields() []string {
+	fields := fieldsMatching("", m.Properties, MappingProperty.IsDate)
+	sort.Strings(fields)
+	return fields
+}
+
+// FieldsOfType returns the dotted paths of every field mapped with one of the types, including
+// fields inside object properties, sorted by path. Multi-fields aren't included.
+func (m Mappings) FieldsOfType(types ...string) []string {
+	fields := fieldsMatching("", m.Properties, func(p MappingProperty) bool {
+		for _, typ := range types {
+			if p.Type == typ {
+				return true
+			}
+		}
+
+		return false
+	})
+
+	sort.Strings(fields)
+	return fields
+}
+
+// Property returns the mapping property at the dotted path, e.g. `event.created`.
+func (m Mappings) Property(path string) (MappingProperty, bool) {
+	props := m.Properties
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		p, ok := props[name]
+		if !ok {
+			return MappingProperty{}, false
+		}
+
+		if i == len(names)-1 {
+			return p, true
+		}
+
+		props = p.Properties
+	}
+
+	return MappingProperty{}, false
+}
+
+// Without returns a copy of the mappings without the properties whose dotted path matches any of
+// the patterns, as `_source` excludes do, e.g. `payload` or `*.embedding`. Excluding an object
+// property excludes all of its properties.
+func (m Mappings) Without(patterns ...string) Mappings {
+	m.Properties = withoutProperties("", m.Properties, patterns)
+	return m
+}
+
+func withoutProperties(prefix string, props map[string]MappingProperty, patterns []string) map[string]MappingProperty {
+	if props == nil {
+		return nil
+	}
+
+	result := make(map[string]MappingProperty, len(props))
+	for name, p := range props {
+		path := prefix + name
+		if matchFieldPattern(path, patterns) {
+			continue
+		}
+
+		p.Properties = withoutProperties(path+".", p.Properties, patterns)
+		result[name] = p
+	}
+
+	return result
+}
+
+// matchFieldPattern reports whether the dotted field path matches any of the wildcard patterns.
+func matchFieldPattern(field string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, field); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func fieldsMatching(prefix string, props map[string]MappingProperty, match func(MappingProperty) bool) []string {
+	fields := []string{}
+	for name, p := range props {
+		path := prefix + name
+		if match(p) {
+			fields = append(fields, path)
+		}
+
+		fields = append(fields, fieldsMatching(path+".", p.Properties, match)...)
+	}
+
+	return fields
+}
+
+// IsDate reports whether the property is a `date` or `date_nanos` field.
+func (p MappingProperty) IsDate() bool {
+	return p.Type == "date" || p.Type == "date_nanos"
 }
 
+// MappingPropertyFieldType is a multi-field mapping, every mapping parameter other than `type`
+// and `ignore_above` is kept verbatim in Extra.
 type MappingPropertyFieldType struct {
-	Type        string `json:"type"`
-	IgnoreAbove int    `json:"ignore_above"`
+	Type        string                     `json:"type"`
+	IgnoreAbove int                        `json:"ignore_above,omitempty"`
+	Extra       map[string]json.RawMessage `json:"-"`
+}
+
+type mappingPropertyFieldType MappingPropertyFieldType
+
+func (f *MappingPropertyFieldType) UnmarshalJSON(b []byte) error {
+	return unmarshalWithExtra(b, (*mappingPropertyFieldType)(f), &f.Extra, "type", "ignore_above")
+}
+
+func (f MappingPropertyFieldType) MarshalJSON() ([]byte, error) {
+	return marshalWithExtra(mappingPropertyFieldType(f), f.Extra)
+}
+
+// Settings is the complete index settings tree as returned by elasticsearch, e.g.
+// `{"index": {"number_of_shards": "1", "analysis": {...}}}`.
+type Settings map[string]any
+
+// DefaultSettingsDeny are the settings that are set by elasticsearch, or are specific to the
+// cluster the index is in, and can't be or shouldn't be set when creating an index.
+var DefaultSettingsDeny = []string{
+	"index.uuid",
+	"index.creation_date",
+	"index.creation_date_string",
+	"index.provided_name",
+	"index.version",
+	"index.history.uuid",
+	"index.routing.allocation",
+	"index.resize",
+	"index.blocks",
+	"index.verified_before_close",
+	"index.frozen",
+	"index.store.snapshot",
+	"index.recovery",
+}
+
+// Get returns the setting at the dotted key as string, e.g. `index.number_of_shards`, or an empty
+// string if it's not set.
+func (s Settings) Get(key string) string {
+	var v any = map[string]any(s)
+	for key != "" {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return ""
+		}
+
+		// the key is looked up in nested form, and in flat form, e.g. `index.number_of_shards`
+		// inside the `index` object or as is.
+		if flat, ok := m[key]; ok {
+			v = flat
+			break
+		}
+
+		name, rest, _ := strings.Cut(key, ".")
+		if v, ok = m[name]; !ok {
+			return ""
+		}
+
+		key = rest
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
 }
 
-type Settings struct {
-	Index IndexSettingInner `json:"index"`
+// Filter returns a copy of the settings with only the allowed settings. Allow and deny rules are
+// dotted keys, matching the setting and every setting under it, e.g. `index.routing` matches
+// `index.routing.allocation.include._tier_preference`. The most specific rule matching a setting
+// wins, allow rules only override deny rules, so a setting not matching any rule is kept.
+func (s Settings) Filter(allow, deny []string) Settings {
+	return filterSettings("", s, allow, deny)
 }
 
-type IndexSettingInner struct {
-	NumberOfShards   string `json:"number_of_shards"`
-	NumberOfReplicas string `json:"number_of_replicas"`
+func filterSettings(prefix string, settings map[string]any, allow, deny []string) map[string]any {
+	result := make(map[string]any, len(settings))
+	for key, v := range settings {
+		path := prefix + key
+		if m, ok := v.(map[string]any); ok && hasSettingRuleUnder(path, allow, deny) {
+			if filtered := filterSettings(path+".", m, allow, deny); len(filtered) > 0 {
+				result[key] = filtered
+			}
+
+			continue
+		}
+
+		if keepSetting(path, allow, deny) {
+			result[key] = v
+		}
+	}
+
+	return result
+}
+
+func keepSetting(path string, allow, deny []string) bool {
+	allowed, denied := longestSettingRule(path, allow), longestSettingRule(path, deny)
+	return denied == 0 || allowed > denied
+}
+
+// longestSettingRule returns the length of the longest rule matching the setting path.
+func longestSettingRule(path string, rules []string) int {
+	longest := 0
+	for _, rule := range rules {
+		if (path == rule || strings.HasPrefix(path, rule+".")) && len(rule) > longest {
+			longest = len(rule)
+		}
+	}
+
+	return longest
+}
+
+func hasSettingRuleUnder(path string, rules ...[]string) bool {
+	for _, rs := range rules {
+		for _, rule := range rs {
+			if strings.HasPrefix(rule, path+".") {
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
 func ParseIndicesGetResponse(res *esapi.Response) ([]IndexSetting, error) {
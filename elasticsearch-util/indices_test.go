@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"reflect"
 	"testing"
 
 	"github.com/elastic/go-elasticsearch/v7/esapi"
@@ -126,7 +127,7 @@ func TestIndexSettingPropertiesMappingParse(t *testing.T) {
 								"ignore_above": 256
 							}
 						}
-					},
+					}
 				}
 			}`),
 			prop: Mappings{
@@ -160,3 +161,433 @@ func compareMapping(t *testing.T, m1, m2 Mappings) {
 		t.Errorf("expecting %d properties, got %d", len(m1.Properties), len(m2.Properties))
 	}
 }
+
+func TestMappingsDateFields(t *testing.T) {
+	b := []byte(`{
+		"properties": {
+			"@timestamp": {
+				"type": "date"
+			},
+			"event": {
+				"properties": {
+					"created": {
+						"type": "date_nanos"
+					},
+					"kind": {
+						"type": "keyword"
+					}
+				}
+			},
+			"message": {
+				"type": "text"
+			}
+		}
+	}`)
+
+	var m Mappings
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := m.DateFields()
+	expected := []string{"@timestamp", "event.created"}
+	if len(fields) != len(expected) {
+		t.Fatalf("expecting date fields %v, got %v", expected, fields)
+	}
+
+	for i := range expected {
+		if fields[i] != expected[i] {
+			t.Errorf("expecting date field '%s', got '%s'", expected[i], fields[i])
+		}
+	}
+
+	keywords := m.FieldsOfType("keyword", "text")
+	if len(keywords) != 2 || keywords[0] != "event.kind" || keywords[1] != "message" {
+		t.Errorf("expecting fields [event.kind message], got %v", keywords)
+	}
+
+	p, ok := m.Property("event.created")
+	if !ok {
+		t.Fatal("expecting property 'event.created' to exist")
+	}
+
+	if p.Type != "date_nanos" {
+		t.Errorf("expecting type 'date_nanos', got '%s'", p.Type)
+	}
+
+	if _, ok := m.Property("event.missing"); ok {
+		t.Error("expecting property 'event.missing' to not exist")
+	}
+}
+
+func TestMappingsWithout(t *testing.T) {
+	m := Mappings{
+		Properties: map[string]MappingProperty{
+			"message": {Type: "text"},
+			"payload": {
+				Properties: map[string]MappingProperty{
+					"raw": {Type: "binary"},
+				},
+			},
+			"doc": {
+				Properties: map[string]MappingProperty{
+					"title":     {Type: "text"},
+					"embedding": {Type: "dense_vector"},
+				},
+			},
+		},
+	}
+
+	without := m.Without("payload", "*.embedding")
+	if _, ok := without.Property("payload"); ok {
+		t.Error("expecting 'payload' to be removed")
+	}
+
+	if _, ok := without.Property("doc.embedding"); ok {
+		t.Error("expecting 'doc.embedding' to be removed")
+	}
+
+	for _, field := range []string{"message", "doc", "doc.title"} {
+		if _, ok := without.Property(field); !ok {
+			t.Errorf("expecting '%s' to be kept", field)
+		}
+	}
+
+	if _, ok := m.Property("doc.embedding"); !ok {
+		t.Error("expecting original mappings to be unchanged")
+	}
+}
+
+func TestSettingsFilter(t *testing.T) {
+	b := []byte(`{
+		"index": {
+			"routing": {
+				"allocation": {
+					"include": {
+						"_tier_preference": "data_content"
+					},
+					"require": {
+						"box_type": "hot"
+					}
+				}
+			},
+			"refresh_interval": "30s",
+			"number_of_shards": "3",
+			"provided_name": "test-index",
+			"creation_date": "1672531200000",
+			"analysis": {
+				"analyzer": {
+					"my.analyzer": {
+						"type": "custom",
+						"tokenizer": "standard",
+						"filter": ["lowercase"]
+					}
+				}
+			},
+			"sort": {
+				"field": "@timestamp",
+				"order": "desc"
+			},
+			"number_of_replicas": "1",
+			"uuid": "some-uuid",
+			"version": {
+				"created": "7170199"
+			}
+		}
+	}`)
+
+	var settings Settings
+	if err := json.Unmarshal(b, &settings); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		allow   []string
+		deny    []string
+		kept    []string
+		dropped []string
+	}{
+		{
+			deny:    DefaultSettingsDeny,
+			kept:    []string{"index.refresh_interval", "index.number_of_shards", "index.number_of_replicas", "index.sort.field"},
+			dropped: []string{"index.uuid", "index.provided_name", "index.creation_date", "index.version.created", "index.routing.allocation.include._tier_preference"},
+		},
+		{
+			allow:   []string{"index.routing.allocation.include._tier_preference"},
+			deny:    DefaultSettingsDeny,
+			kept:    []string{"index.routing.allocation.include._tier_preference", "index.number_of_shards", "index.refresh_interval"},
+			dropped: []string{"index.uuid", "index.provided_name", "index.routing.allocation.require.box_type"},
+		},
+		{
+			allow:   []string{"index"},
+			deny:    append([]string{"index.sort"}, DefaultSettingsDeny...),
+			kept:    []string{"index.number_of_shards", "index.refresh_interval"},
+			dropped: []string{"index.sort.field", "index.uuid"},
+		},
+	} {
+		filtered := settings.Filter(c.allow, c.deny)
+		for _, key := range c.kept {
+			if filtered.Get(key) == "" {
+				t.Errorf("expecting '%s' to be kept with allow %v and deny %v", key, c.allow, c.deny)
+			}
+		}
+
+		for _, key := range c.dropped {
+			if filtered.Get(key) != "" {
+				t.Errorf("expecting '%s' to be dropped with allow %v and deny %v", key, c.allow, c.deny)
+			}
+		}
+	}
+
+	// analyzer names with dots must be kept as is.
+	filtered := settings.Filter(nil, DefaultSettingsDeny)
+	analyzers := filtered["index"].(map[string]any)["analysis"].(map[string]any)["analyzer"].(map[string]any)
+	if _, ok := analyzers["my.analyzer"]; !ok {
+		t.Errorf("expecting analyzer 'my.analyzer' to be kept, got %v", analyzers)
+	}
+
+	if settings.Get("index.uuid") != "some-uuid" {
+		t.Error("expecting original settings to be unchanged")
+	}
+
+	flat := Settings{"index.number_of_shards": "2"}
+	if flat.Get("index.number_of_shards") != "2" {
+		t.Errorf("expecting flat setting '2', got '%s'", flat.Get("index.number_of_shards"))
+	}
+}
+
+func TestMappingsRoundTrip(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		b    []byte
+	}{
+		{
+			name: "ecs logs",
+			b: []byte(`{
+				"dynamic": "strict",
+				"date_detection": false,
+				"_meta": {
+					"managed_by": "fleet"
+				},
+				"_source": {
+					"excludes": ["event.original"]
+				},
+				"dynamic_templates": [
+					{
+						"strings_as_keyword": {
+							"match_mapping_type": "string",
+							"mapping": {
+								"type": "keyword",
+								"ignore_above": 1024
+							}
+						}
+					}
+				],
+				"properties": {
+					"@timestamp": {
+						"type": "date",
+						"format": "strict_date_optional_time||epoch_millis"
+					},
+					"event": {
+						"properties": {
+							"created": {
+								"type": "date_nanos"
+							},
+							"original": {
+								"type": "keyword",
+								"index": false,
+								"doc_values": false
+							}
+						}
+					},
+					"message": {
+						"type": "match_only_text",
+						"fields": {
+							"keyword": {
+								"type": "keyword",
+								"ignore_above": 256,
+								"normalizer": "lowercase"
+							},
+							"english": {
+								"type": "text",
+								"analyzer": "english"
+							}
+						}
+					},
+					"first_name": {
+						"type": "text",
+						"copy_to": ["full_name"]
+					},
+					"full_name": {
+						"type": "text"
+					},
+					"source": {
+						"properties": {
+							"ip": {
+								"type": "ip"
+							},
+							"geo": {
+								"properties": {
+									"location": {
+										"type": "geo_point"
+									}
+								}
+							}
+						}
+					},
+					"client_ip": {
+						"type": "alias",
+						"path": "source.ip"
+					},
+					"payload": {
+						"type": "object",
+						"enabled": false
+					}
+				},
+				"runtime": {
+					"day_of_week": {
+						"type": "keyword",
+						"script": {
+							"source": "emit(doc['@timestamp'].value.dayOfWeekEnum.toString())"
+						}
+					}
+				}
+			}`),
+		},
+		{
+			name: "search and vectors",
+			b: []byte(`{
+				"_routing": {
+					"required": true
+				},
+				"properties": {
+					"relation": {
+						"type": "join",
+						"eager_global_ordinals": true,
+						"relations": {
+							"question": "answer"
+						}
+					},
+					"embedding": {
+						"type": "dense_vector",
+						"dims": 384,
+						"index": true,
+						"similarity": "cosine"
+					},
+					"price": {
+						"type": "scaled_float",
+						"scaling_factor": 100
+					},
+					"tags": {
+						"type": "nested",
+						"include_in_parent": true,
+						"properties": {
+							"name": {
+								"type": "keyword",
+								"null_value": "NULL"
+							}
+						}
+					},
+					"title": {
+						"type": "text",
+						"analyzer": "my_analyzer",
+						"search_analyzer": "standard",
+						"term_vector": "with_positions_offsets"
+					}
+				}
+			}`),
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var m Mappings
+			if err := json.Unmarshal(c.b, &m); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := json.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			compareJSON(t, c.b, b)
+		})
+	}
+}
+
+func TestIndexSettingParseRoundTrip(t *testing.T) {
+	b := []byte(`{
+		"aliases": {
+			"logs": {
+				"is_write_index": true
+			}
+		},
+		"mappings": {
+			"dynamic": false,
+			"properties": {
+				"@timestamp": {
+					"type": "date"
+				},
+				"host": {
+					"properties": {
+						"name": {
+							"type": "keyword",
+							"fields": {
+								"text": {
+									"type": "text"
+								}
+							}
+						}
+					}
+				}
+			}
+		},
+		"settings": {
+			"index": {
+				"number_of_shards": "1",
+				"refresh_interval": "30s",
+				"codec": "best_compression"
+			}
+		}
+	}`)
+
+	var inner SettingInner
+	if err := json.Unmarshal(b, &inner); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := IndexSetting{Index: "test-index", Setting: inner}.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compareJSON(t, b, parsed)
+
+	p, ok := inner.Mappings.Property("host.name")
+	if !ok {
+		t.Fatal("expecting property 'host.name' to exist")
+	}
+
+	if p.Fields["text"].Type != "text" {
+		t.Errorf("expecting multi-field 'text' of type 'text', got '%s'", p.Fields["text"].Type)
+	}
+
+	if dynamic, _ := inner.Mappings.Param("dynamic"); string(dynamic) != "false" {
+		t.Errorf("expecting dynamic 'false', got '%s'", dynamic)
+	}
+}
+
+func compareJSON(t *testing.T, expected, actual []byte) {
+	t.Helper()
+	var e, a any
+	if err := json.Unmarshal(expected, &e); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(actual, &a); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(e, a) {
+		t.Errorf("expecting %s, got %s", expected, actual)
+	}
+}
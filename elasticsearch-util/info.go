@@ -0,0 +1,66 @@
+package esutil
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+type ClusterInfo struct {
+	Name        string         `json:"name"`
+	ClusterName string         `json:"cluster_name"`
+	Version     ClusterVersion `json:"version"`
+}
+
+type ClusterVersion struct {
+	Number       string `json:"number"`
+	Distribution string `json:"distribution,omitempty"`
+}
+
+// Major returns the major and minor version number, e.g. 7 and 10 for `7.10.2`.
+func (v ClusterVersion) Major() (major, minor int) {
+	parts := strings.SplitN(v.Number, ".", 3)
+	major = stringToInt(parts[0])
+	if len(parts) > 1 {
+		minor = stringToInt(parts[1])
+	}
+
+	return major, minor
+}
+
+// SupportsPointInTime reports whether the cluster has the point-in-time API, which is available
+// since elasticsearch 7.10.
+func (i ClusterInfo) SupportsPointInTime() bool {
+	if i.Version.Distribution != "" && i.Version.Distribution != "elasticsearch" {
+		return false
+	}
+
+	major, minor := i.Version.Major()
+	return major > 7 || (major == 7 && minor >= 10)
+}
+
+// SupportsSeqNoPrimaryTerm reports whether searches can return the `_seq_no` and `_primary_term`
+// of documents, which is available since elasticsearch 6.7 and on every opensearch version.
+func (i ClusterInfo) SupportsSeqNoPrimaryTerm() bool {
+	if i.Version.Distribution != "" && i.Version.Distribution != "elasticsearch" {
+		return true
+	}
+
+	major, minor := i.Version.Major()
+	return major > 6 || (major == 6 && minor >= 7)
+}
+
+func ParseInfo(res *esapi.Response) (ClusterInfo, error) {
+	defer res.Body.Close()
+	if res.IsError() {
+		return ClusterInfo{}, ParseCommonError(res.Body)
+	}
+
+	var info ClusterInfo
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return ClusterInfo{}, err
+	}
+
+	return info, nil
+}
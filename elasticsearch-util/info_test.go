@@ -0,0 +1,125 @@
+package esutil
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+func TestParseInfo(t *testing.T) {
+	for _, c := range []struct {
+		b      []byte
+		status int
+		number string
+		pit    bool
+		seqNo  bool
+	}{
+		{
+			b: []byte(`{
+				"name": "node-1",
+				"cluster_name": "some-cluster",
+				"version": {
+					"number": "7.17.1",
+					"build_flavor": "default"
+				},
+				"tagline": "You Know, for Search"
+			}`),
+			status: 200,
+			number: "7.17.1",
+			pit:    true,
+			seqNo:  true,
+		},
+		{
+			b: []byte(`{
+				"name": "node-1",
+				"cluster_name": "some-cluster",
+				"version": {
+					"number": "7.9.3"
+				},
+				"tagline": "You Know, for Search"
+			}`),
+			status: 200,
+			number: "7.9.3",
+			seqNo:  true,
+		},
+		{
+			b: []byte(`{
+				"name": "node-1",
+				"cluster_name": "some-cluster",
+				"version": {
+					"number": "6.8.23"
+				},
+				"tagline": "You Know, for Search"
+			}`),
+			status: 200,
+			number: "6.8.23",
+			seqNo:  true,
+		},
+		{
+			b: []byte(`{
+				"name": "node-1",
+				"cluster_name": "some-cluster",
+				"version": {
+					"number": "6.5.4"
+				},
+				"tagline": "You Know, for Search"
+			}`),
+			status: 200,
+			number: "6.5.4",
+		},
+		{
+			b: []byte(`{
+				"name": "node-1",
+				"cluster_name": "some-cluster",
+				"version": {
+					"distribution": "opensearch",
+					"number": "2.5.0"
+				},
+				"tagline": "The OpenSearch Project: https://opensearch.org/"
+			}`),
+			status: 200,
+			number: "2.5.0",
+			seqNo:  true,
+		},
+		{
+			b: []byte(`{
+				"name": "node-1",
+				"cluster_name": "some-cluster",
+				"version": {
+					"number": "8.6.0"
+				},
+				"tagline": "You Know, for Search"
+			}`),
+			status: 200,
+			number: "8.6.0",
+			pit:    true,
+			seqNo:  true,
+		},
+	} {
+		t.Run("test parse info", func(t *testing.T) {
+			res := &esapi.Response{
+				StatusCode: c.status,
+				Body:       io.NopCloser(bytes.NewReader(c.b)),
+			}
+
+			info, err := ParseInfo(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.Version.Number != c.number {
+				t.Errorf("expecting version '%s', got '%s'", c.number, info.Version.Number)
+			}
+
+			if info.SupportsPointInTime() != c.pit {
+				t.Errorf("expecting point-in-time support %t for '%s', got %t", c.pit, c.number, info.SupportsPointInTime())
+			}
+
+			if info.SupportsSeqNoPrimaryTerm() != c.seqNo {
+				t.Errorf("expecting seq_no_primary_term support %t for '%s', got %t", c.seqNo, c.number, info.SupportsSeqNoPrimaryTerm())
+			}
+		})
+	}
+}
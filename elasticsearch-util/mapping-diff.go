@@ -0,0 +1,224 @@
+package esutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+// MappingDiff is the difference between a source and a destination mappings, fields are dotted
+// paths, multi-fields included, e.g. `message.keyword`.
+type MappingDiff struct {
+	// Added are fields the source has and the destination doesn't, which can be added to the
+	// destination mappings. Fields inside an added object aren't listed.
+	Added []string
+	// Missing are fields the destination has and the source doesn't.
+	Missing []string
+	// Conflicts are fields mapped with different types on source and destination.
+	Conflicts []MappingConflict
+}
+
+type MappingConflict struct {
+	Field       string `json:"field"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+func (d MappingDiff) Equal() bool {
+	return len(d.Added) == 0 && len(d.Missing) == 0 && len(d.Conflicts) == 0
+}
+
+func (d MappingDiff) String() string {
+	var b strings.Builder
+	for _, field := range d.Added {
+		fmt.Fprintf(&b, "+ %s\n", field)
+	}
+
+	for _, field := range d.Missing {
+		fmt.Fprintf(&b, "- %s\n", field)
+	}
+
+	for _, c := range d.Conflicts {
+		fmt.Fprintf(&b, "! %s: '%s' on source, '%s' on destination\n", c.Field, c.Source, c.Destination)
+	}
+
+	return b.String()
+}
+
+// DiffMappings compares the source mappings with the destination mappings.
+func DiffMappings(source, dest Mappings) MappingDiff {
+	sourceTypes, destTypes := fieldTypes("", source.Properties), fieldTypes("", dest.Properties)
+	diff := MappingDiff{}
+	for field, st := range sourceTypes {
+		dt, ok := destTypes[field]
+		switch {
+		case !ok:
+			if _, ok := sourceTypes[parentField(field)]; ok {
+				if _, ok := destTypes[parentField(field)]; !ok {
+					continue
+				}
+			}
+
+			diff.Added = append(diff.Added, field)
+		case st != dt:
+			diff.Conflicts = append(diff.Conflicts, MappingConflict{Field: field, Source: st, Destination: dt})
+		}
+	}
+
+	for field := range destTypes {
+		if _, ok := sourceTypes[field]; ok {
+			continue
+		}
+
+		if _, ok := destTypes[parentField(field)]; ok {
+			if _, ok := sourceTypes[parentField(field)]; !ok {
+				continue
+			}
+		}
+
+		diff.Missing = append(diff.Missing, field)
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Missing)
+	sort.Slice(diff.Conflicts, func(i, j int) bool { return diff.Conflicts[i].Field < diff.Conflicts[j].Field })
+	return diff
+}
+
+// fieldTypes returns the type of every field by dotted path, object fields without type are
+// `object`.
+func fieldTypes(prefix string, props map[string]MappingProperty) map[string]string {
+	types := map[string]string{}
+	for name, p := range props {
+		path := prefix + name
+		typ := p.Type
+		if typ == "" {
+			typ = "object"
+		}
+
+		types[path] = typ
+		for field, f := range p.Fields {
+			types[path+"."+field] = f.Type
+		}
+
+		for field, typ := range fieldTypes(path+".", p.Properties) {
+			types[field] = typ
+		}
+	}
+
+	return types
+}
+
+func parentField(field string) string {
+	if i := strings.LastIndex(field, "."); i >= 0 {
+		return field[:i]
+	}
+
+	return ""
+}
+
+// Subset returns mappings with only the fields, and the objects containing them, e.g. to add the
+// fields to existing mappings. A field is either a property, with every property inside it, or
+// a multi-field.
+func (m Mappings) Subset(fields ...string) Mappings {
+	subset := Mappings{Properties: map[string]MappingProperty{}}
+	for _, field := range fields {
+		addSubsetField(subset.Properties, m.Properties, strings.Split(field, "."))
+	}
+
+	return subset
+}
+
+func addSubsetField(dst, src map[string]MappingProperty, names []string) {
+	p, ok := src[names[0]]
+	if !ok {
+		return
+	}
+
+	if len(names) == 1 {
+		dst[names[0]] = p
+		return
+	}
+
+	if f, ok := p.Fields[names[1]]; ok && len(names) == 2 {
+		existing, ok := dst[names[0]]
+		if !ok {
+			existing = MappingProperty{Type: p.Type, Extra: p.Extra}
+		}
+
+		fields := map[string]MappingPropertyFieldType{}
+		for name, f := range existing.Fields {
+			fields[name] = f
+		}
+
+		fields[names[1]] = f
+		existing.Fields = fields
+		dst[names[0]] = existing
+		return
+	}
+
+	existing, ok := dst[names[0]]
+	if !ok {
+		existing = MappingProperty{Type: p.Type}
+	}
+
+	if existing.Properties == nil {
+		existing.Properties = map[string]MappingProperty{}
+	}
+
+	addSubsetField(existing.Properties, p.Properties, names[1:])
+	dst[names[0]] = existing
+}
+
+type IndexMappings struct {
+	Mappings Mappings `json:"mappings"`
+}
+
+// ParseGetMappingResponse parses the `_mapping` response, keyed by index name.
+func ParseGetMappingResponse(res *esapi.Response) (map[string]Mappings, error) {
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, ParseCommonError(res.Body)
+	}
+
+	var results map[string]IndexMappings
+	if err := json.NewDecoder(res.Body).Decode(&results); err != nil {
+		return nil, err
+	}
+
+	mappings := make(map[string]Mappings, len(results))
+	for index, m := range results {
+		mappings[index] = m.Mappings
+	}
+
+	return mappings, nil
+}
+
+type AcknowledgedResponse struct {
+	Acknowledged bool `json:"acknowledged"`
+}
+
+// ErrNotAcknowledged is returned when elasticsearch doesn't acknowledge a request in time.
+var ErrNotAcknowledged = errors.New("request not acknowledged")
+
+func ParseAcknowledgedResponse(res *esapi.Response) error {
+	defer res.Body.Close()
+	if res.IsError() {
+		return ParseCommonError(res.Body)
+	}
+
+	var resp AcknowledgedResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return err
+	}
+
+	if !resp.Acknowledged {
+		return ErrNotAcknowledged
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package esutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+func TestDiffMappings(t *testing.T) {
+	var source, dest Mappings
+	if err := json.Unmarshal([]byte(`{
+		"properties": {
+			"@timestamp": {"type": "date"},
+			"message": {
+				"type": "text",
+				"fields": {
+					"keyword": {"type": "keyword", "ignore_above": 256}
+				}
+			},
+			"status": {"type": "keyword"},
+			"user": {
+				"properties": {
+					"name": {"type": "keyword"},
+					"email": {"type": "keyword"}
+				}
+			},
+			"geo": {
+				"properties": {
+					"location": {"type": "geo_point"}
+				}
+			}
+		}
+	}`), &source); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal([]byte(`{
+		"properties": {
+			"@timestamp": {"type": "date"},
+			"message": {"type": "text"},
+			"status": {"type": "long"},
+			"user": {
+				"properties": {
+					"name": {"type": "keyword"}
+				}
+			},
+			"legacy": {
+				"properties": {
+					"id": {"type": "keyword"}
+				}
+			}
+		}
+	}`), &dest); err != nil {
+		t.Fatal(err)
+	}
+
+	diff := DiffMappings(source, dest)
+	expected := MappingDiff{
+		Added:   []string{"geo", "message.keyword", "user.email"},
+		Missing: []string{"legacy"},
+		Conflicts: []MappingConflict{
+			{Field: "status", Source: "keyword", Destination: "long"},
+		},
+	}
+
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("expecting diff %+v, got %+v", expected, diff)
+	}
+
+	if DiffMappings(source, source).Equal() != true {
+		t.Error("expecting no difference between identical mappings")
+	}
+
+	b, err := json.Marshal(source.Subset(diff.Added...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compareJSON(t, []byte(`{
+		"properties": {
+			"geo": {
+				"properties": {
+					"location": {"type": "geo_point"}
+				}
+			},
+			"message": {
+				"type": "text",
+				"fields": {
+					"keyword": {"type": "keyword", "ignore_above": 256}
+				}
+			},
+			"user": {
+				"properties": {
+					"email": {"type": "keyword"}
+				}
+			}
+		}
+	}`), b)
+}
+
+func TestParseGetMappingResponse(t *testing.T) {
+	res := &esapi.Response{
+		StatusCode: 200,
+		Body: io.NopCloser(bytes.NewReader([]byte(`{
+			"test-index": {
+				"mappings": {
+					"dynamic": "strict",
+					"properties": {
+						"@timestamp": {"type": "date"}
+					}
+				}
+			}
+		}`))),
+	}
+
+	mappings, err := ParseGetMappingResponse(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, ok := mappings["test-index"]
+	if !ok {
+		t.Fatal("expecting mappings of 'test-index'")
+	}
+
+	if p, ok := m.Property("@timestamp"); !ok || p.Type != "date" {
+		t.Errorf("expecting '@timestamp' of type 'date', got %+v", p)
+	}
+}
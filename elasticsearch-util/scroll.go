@@ -0,0 +1,63 @@
+package esutil
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+var ErrScrollNotFound = errors.New("scroll ID not found")
+
+type ScrollPage struct {
+	ScrollID string
+	Results  []Document
+}
+
+// ParseScroll parses the response of a search with scroll parameter, or of a scroll request.
+func ParseScroll(res *esapi.Response) (ScrollPage, error) {
+	defer res.Body.Close()
+	if res.IsError() {
+		return ScrollPage{}, ParseCommonError(res.Body)
+	}
+
+	var response SearchResponse
+	if err := decodeSearchResponse(res.Body, &response); err != nil {
+		return ScrollPage{}, err
+	}
+
+	page := ScrollPage{
+		ScrollID: response.ScrollID,
+		Results:  response.Hits.Hits,
+	}
+
+	return page, nil
+}
+
+type ClearScrollResponse struct {
+	Succeeded bool `json:"succeeded"`
+	NumFreed  int  `json:"num_freed"`
+}
+
+func ParseClearScroll(res *esapi.Response) error {
+	defer res.Body.Close()
+	if res.IsError() {
+		if res.StatusCode == http.StatusNotFound {
+			return ErrScrollNotFound
+		}
+
+		return ParseCommonError(res.Body)
+	}
+
+	var resp ClearScrollResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return err
+	}
+
+	if !resp.Succeeded {
+		return ErrScrollNotFound
+	}
+
+	return nil
+}
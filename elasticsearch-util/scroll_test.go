@@ -0,0 +1,126 @@
+package esutil
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+func TestParseScroll(t *testing.T) {
+	for _, c := range []struct {
+		b        []byte
+		status   int
+		scrollID string
+		count    int
+		err      error
+	}{
+		{
+			b: []byte(`{
+				"_scroll_id": "some-scroll-id==",
+				"took": 3,
+				"timed_out": false,
+				"hits": {
+					"total": 2,
+					"max_score": null,
+					"hits": [
+						{
+							"_index": "foo_bar_qux",
+							"_type": "doc",
+							"_id": "some-foo-1",
+							"_score": null,
+							"_source": {
+								"foo": "bar"
+							},
+							"sort": [0]
+						},
+						{
+							"_index": "foo_bar_qux",
+							"_type": "doc",
+							"_id": "some-foo-2",
+							"_score": null,
+							"_source": {
+								"foo": "bar"
+							},
+							"sort": [1]
+						}
+					]
+				}
+			}`),
+			status:   200,
+			scrollID: "some-scroll-id==",
+			count:    2,
+		},
+		{
+			b: []byte(`{
+				"error": {
+					"root_cause": [
+						{
+							"type": "search_context_missing_exception",
+							"reason": "No search context found for id [42]"
+						}
+					],
+					"type": "search_phase_execution_exception",
+					"reason": "all shards failed"
+				},
+				"status": 404
+			}`),
+			status: 404,
+			err: CommonErrorResponse{
+				Status: 404,
+				Err: CommonError{
+					Type:   "search_phase_execution_exception",
+					Reason: "all shards failed",
+				},
+			},
+		},
+	} {
+		t.Run("test parse scroll", func(t *testing.T) {
+			res := &esapi.Response{
+				StatusCode: c.status,
+				Body:       io.NopCloser(bytes.NewReader(c.b)),
+			}
+
+			page, err := ParseScroll(res)
+			if err != c.err {
+				t.Errorf("expecting error %v, got %v", c.err, err)
+			}
+
+			if page.ScrollID != c.scrollID {
+				t.Errorf("expecting scroll ID '%s', got '%s'", c.scrollID, page.ScrollID)
+			}
+
+			if len(page.Results) != c.count {
+				t.Errorf("expecting %d documents, got %d", c.count, len(page.Results))
+			}
+		})
+	}
+}
+
+func TestParseClearScroll(t *testing.T) {
+	for _, c := range []struct {
+		b      []byte
+		err    error
+		status int
+	}{
+		{
+			b:      []byte(`{"succeeded": true, "num_freed": 3}`),
+			status: 200,
+		},
+		{
+			b:      []byte(`{"succeeded": true, "num_freed": 0}`),
+			status: 404,
+			err:    ErrScrollNotFound,
+		},
+	} {
+		res := &esapi.Response{
+			StatusCode: c.status,
+			Body:       io.NopCloser(bytes.NewReader(c.b)),
+		}
+
+		if err := ParseClearScroll(res); err != c.err {
+			t.Errorf("expecting error %v, got %v", c.err, err)
+		}
+	}
+}
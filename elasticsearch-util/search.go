@@ -2,20 +2,43 @@ package esutil
 
 import (
 	"encoding/json"
+	"io"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 )
 
 type SearchResponse struct {
-	Hits SearchHits `json:"hits"`
+	ScrollID     string                     `json:"_scroll_id,omitempty"`
+	Hits         SearchHits                 `json:"hits"`
+	Aggregations map[string]json.RawMessage `json:"aggregations,omitempty"`
 }
 
 type SearchHits struct {
-	Total struct {
-		Value    int    `json:"value"`
-		Relation string `json:"relation"`
-	} `json:"total"`
-	Hits []Document `json:"hits"`
+	Total SearchTotal `json:"total"`
+	Hits  []Document  `json:"hits"`
+}
+
+type SearchTotal struct {
+	Value    int    `json:"value"`
+	Relation string `json:"relation"`
+}
+
+// UnmarshalJSON also accepts the plain number total returned by elasticsearch 6.x.
+func (t *SearchTotal) UnmarshalJSON(b []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err == nil {
+		value, err := n.Int64()
+		if err != nil {
+			return err
+		}
+
+		t.Value, t.Relation = int(value), "eq"
+		return nil
+	}
+
+	type total SearchTotal
+	return json.Unmarshal(b, (*total)(t))
 }
 
 func ParseSearch(res *esapi.Response) ([]Document, error) {
@@ -25,7 +48,7 @@ func ParseSearch(res *esapi.Response) ([]Document, error) {
 	}
 
 	var response SearchResponse
-	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+	if err := decodeSearchResponse(res.Body, &response); err != nil {
 		return nil, err
 	}
 
@@ -44,7 +67,7 @@ func ParseSearchWithMetadata(res *esapi.Response) (SearchMetadata, error) {
 	}
 
 	var response SearchResponse
-	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+	if err := decodeSearchResponse(res.Body, &response); err != nil {
 		return SearchMetadata{}, err
 	}
 
@@ -55,3 +78,89 @@ func ParseSearchWithMetadata(res *esapi.Response) (SearchMetadata, error) {
 
 	return meta, nil
 }
+
+// MaxAggregation is the result of a `max` metric aggregation, Value is nil if no document has the field.
+type MaxAggregation struct {
+	Value         *float64 `json:"value"`
+	ValueAsString string   `json:"value_as_string,omitempty"`
+}
+
+// Time returns the aggregated value of a `date` or `date_nanos` field as time, which is returned
+// by elasticsearch as milliseconds since epoch.
+func (m MaxAggregation) Time() (time.Time, bool) {
+	if m.Value == nil {
+		return time.Time{}, false
+	}
+
+	return time.UnixMilli(int64(*m.Value)).UTC(), true
+}
+
+// ParseMaxAggregation parses the `max` aggregation with the specified name from a search response.
+func ParseMaxAggregation(res *esapi.Response, name string) (MaxAggregation, error) {
+	defer res.Body.Close()
+	if res.IsError() {
+		return MaxAggregation{}, ParseCommonError(res.Body)
+	}
+
+	var response SearchResponse
+	if err := decodeSearchResponse(res.Body, &response); err != nil {
+		return MaxAggregation{}, err
+	}
+
+	var agg MaxAggregation
+	b, ok := response.Aggregations[name]
+	if !ok {
+		return agg, nil
+	}
+
+	if err := json.Unmarshal(b, &agg); err != nil {
+		return MaxAggregation{}, err
+	}
+
+	return agg, nil
+}
+
+// DateHistogramAggregation is the result of a `date_histogram` bucket aggregation.
+type DateHistogramAggregation struct {
+	Buckets []DateHistogramBucket `json:"buckets"`
+}
+
+type DateHistogramBucket struct {
+	Key         int64  `json:"key"`
+	KeyAsString string `json:"key_as_string"`
+	DocCount    int64  `json:"doc_count"`
+}
+
+// ParseDateHistogramAggregation parses the `date_histogram` aggregation with the specified name
+// from a search response.
+func ParseDateHistogramAggregation(res *esapi.Response, name string) (DateHistogramAggregation, error) {
+	defer res.Body.Close()
+	if res.IsError() {
+		return DateHistogramAggregation{}, ParseCommonError(res.Body)
+	}
+
+	var response SearchResponse
+	if err := decodeSearchResponse(res.Body, &response); err != nil {
+		return DateHistogramAggregation{}, err
+	}
+
+	var agg DateHistogramAggregation
+	b, ok := response.Aggregations[name]
+	if !ok {
+		return agg, nil
+	}
+
+	if err := json.Unmarshal(b, &agg); err != nil {
+		return DateHistogramAggregation{}, err
+	}
+
+	return agg, nil
+}
+
+// decodeSearchResponse decodes numbers as json.Number, so `date_nanos` sort values
+// survive the round-trip into search_after without losing precision.
+func decodeSearchResponse(r io.Reader, response *SearchResponse) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return dec.Decode(response)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 )
@@ -145,6 +146,53 @@ func TestParseSearchResponse(t *testing.T) {
 				}
 			},
 		},
+		{
+			b: []byte(`{
+				"hits": {
+					"total": {
+						"value": 2,
+						"relation": "eq"
+					},
+					"hits": [
+						{
+							"_index": "foo_bar_qux",
+							"_id": "child-1",
+							"_version": 3,
+							"_seq_no": 12,
+							"_primary_term": 1,
+							"_routing": "parent-1",
+							"_source": {
+								"foo": "bar"
+							}
+						},
+						{
+							"_index": "foo_bar_qux",
+							"_id": "parent-1",
+							"_source": {
+								"foo": "bar"
+							}
+						}
+					]
+				}
+			}`),
+			count:  2,
+			status: 200,
+			verify: func(t *testing.T, docs []Document) {
+				child := docs[0].DocumentMetadata
+				if child.Routing != "parent-1" {
+					t.Errorf("expecting routing 'parent-1', got '%s'", child.Routing)
+				}
+
+				if child.Version == nil || *child.Version != 3 || child.SeqNo == nil || *child.SeqNo != 12 || child.PrimaryTerm == nil || *child.PrimaryTerm != 1 {
+					t.Errorf("expecting version 3, seq_no 12 and primary_term 1, got %v, %v and %v", child.Version, child.SeqNo, child.PrimaryTerm)
+				}
+
+				parent := docs[1].DocumentMetadata
+				if parent.Routing != "" || parent.Version != nil || parent.SeqNo != nil || parent.PrimaryTerm != nil {
+					t.Errorf("expecting no routing, version, seq_no and primary_term, got %+v", parent)
+				}
+			},
+		},
 		{
 			b: []byte(`
 			{
@@ -397,3 +445,111 @@ func TestParseSearchResponseWithCustomData(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMaxAggregation(t *testing.T) {
+	for _, c := range []struct {
+		b      []byte
+		status int
+		ok     bool
+		time   time.Time
+	}{
+		{
+			b: []byte(`{
+				"took": 3,
+				"timed_out": false,
+				"hits": {
+					"total": {
+						"value": 42,
+						"relation": "eq"
+					},
+					"hits": []
+				},
+				"aggregations": {
+					"max_time": {
+						"value": 1672531200000.0,
+						"value_as_string": "2023-01-01T00:00:00.000Z"
+					}
+				}
+			}`),
+			status: 200,
+			ok:     true,
+			time:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			b: []byte(`{
+				"took": 1,
+				"timed_out": false,
+				"hits": {
+					"total": {
+						"value": 0,
+						"relation": "eq"
+					},
+					"hits": []
+				},
+				"aggregations": {
+					"max_time": {
+						"value": null
+					}
+				}
+			}`),
+			status: 200,
+		},
+	} {
+		t.Run("test parse max aggregation", func(t *testing.T) {
+			res := &esapi.Response{
+				StatusCode: c.status,
+				Body:       io.NopCloser(bytes.NewReader(c.b)),
+			}
+
+			agg, err := ParseMaxAggregation(res, "max_time")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tm, ok := agg.Time()
+			if ok != c.ok {
+				t.Errorf("expecting ok %t, got %t", c.ok, ok)
+			}
+
+			if !tm.Equal(c.time) {
+				t.Errorf("expecting time '%s', got '%s'", c.time, tm)
+			}
+		})
+	}
+}
+
+func TestParseDateHistogramAggregation(t *testing.T) {
+	res := &esapi.Response{
+		StatusCode: 200,
+		Body: io.NopCloser(bytes.NewReader([]byte(`{
+			"hits": {
+				"total": {
+					"value": 5,
+					"relation": "eq"
+				},
+				"hits": []
+			},
+			"aggregations": {
+				"buckets": {
+					"buckets": [
+						{"key_as_string": "2023-01-01T00:00:00.000Z", "key": 1672531200000, "doc_count": 3},
+						{"key_as_string": "2023-01-02T00:00:00.000Z", "key": 1672617600000, "doc_count": 2}
+					]
+				}
+			}
+		}`))),
+	}
+
+	agg, err := ParseDateHistogramAggregation(res, "buckets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(agg.Buckets) != 2 {
+		t.Fatalf("expecting 2 buckets, got %d", len(agg.Buckets))
+	}
+
+	if b := agg.Buckets[1]; b.Key != 1672617600000 || b.KeyAsString != "2023-01-02T00:00:00.000Z" || b.DocCount != 2 {
+		t.Errorf("expecting bucket '2023-01-02T00:00:00.000Z' with 2 documents, got %+v", b)
+	}
+}
@@ -0,0 +1,42 @@
+package esutil
+
+import (
+	"encoding/json"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+type IndexStats struct {
+	Docs struct {
+		Count int64 `json:"count"`
+	} `json:"docs"`
+	Store struct {
+		SizeInBytes int64 `json:"size_in_bytes"`
+	} `json:"store"`
+}
+
+type IndicesStatsResponse struct {
+	Indices map[string]struct {
+		Primaries IndexStats `json:"primaries"`
+	} `json:"indices"`
+}
+
+// ParseIndicesStatsResponse returns the primary shards stats of every index.
+func ParseIndicesStatsResponse(res *esapi.Response) (map[string]IndexStats, error) {
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, ParseCommonError(res.Body)
+	}
+
+	var resp IndicesStatsResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]IndexStats, len(resp.Indices))
+	for index, s := range resp.Indices {
+		stats[index] = s.Primaries
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,86 @@
+package esutil
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+func TestParseIndicesStatsResponse(t *testing.T) {
+	for _, c := range []struct {
+		b      []byte
+		status int
+		stats  map[string]IndexStats
+		err    bool
+	}{
+		{
+			b: []byte(`{
+				"_shards": {"total": 4, "successful": 2, "failed": 0},
+				"_all": {
+					"primaries": {"docs": {"count": 15, "deleted": 0}, "store": {"size_in_bytes": 3000}}
+				},
+				"indices": {
+					"logs-1": {
+						"uuid": "some-uuid",
+						"primaries": {"docs": {"count": 10, "deleted": 2}, "store": {"size_in_bytes": 2000}},
+						"total": {"docs": {"count": 20, "deleted": 4}, "store": {"size_in_bytes": 4000}}
+					},
+					"logs-2": {
+						"uuid": "other-uuid",
+						"primaries": {"docs": {"count": 5, "deleted": 0}, "store": {"size_in_bytes": 1000}},
+						"total": {"docs": {"count": 10, "deleted": 0}, "store": {"size_in_bytes": 2000}}
+					}
+				}
+			}`),
+			status: 200,
+			stats: map[string]IndexStats{
+				"logs-1": indexStats(10, 2000),
+				"logs-2": indexStats(5, 1000),
+			},
+		},
+		{
+			b:      []byte(`{"error": {"type": "index_not_found_exception", "reason": "no such index [foo]"}, "status": 404}`),
+			status: 404,
+			err:    true,
+		},
+	} {
+		t.Run("test parse indices stats", func(t *testing.T) {
+			res := &esapi.Response{
+				StatusCode: c.status,
+				Body:       io.NopCloser(bytes.NewReader(c.b)),
+			}
+
+			stats, err := ParseIndicesStatsResponse(res)
+			if c.err {
+				if err == nil {
+					t.Errorf("expecting error, got nil")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expecting no error, got %v", err)
+			}
+
+			if len(stats) != len(c.stats) {
+				t.Fatalf("expecting %d indices, got %d", len(c.stats), len(stats))
+			}
+
+			for index, expected := range c.stats {
+				if stats[index] != expected {
+					t.Errorf("expecting stats of index '%s' to be %+v, got %+v", index, expected, stats[index])
+				}
+			}
+		})
+	}
+}
+
+func indexStats(count, size int64) IndexStats {
+	var s IndexStats
+	s.Docs.Count = count
+	s.Store.SizeInBytes = size
+	return s
+}
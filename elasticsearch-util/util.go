@@ -1,6 +1,7 @@
 package esutil
 
 import (
+	"encoding/json"
 	"math"
 	"strconv"
 )
@@ -25,3 +26,47 @@ func stringToInt(s string) int {
 
 	return int(n)
 }
+
+// unmarshalWithExtra decodes b into v, and every key other than the known keys of v into extra.
+func unmarshalWithExtra(b []byte, v any, extra *map[string]json.RawMessage, known ...string) error {
+	if err := json.Unmarshal(b, v); err != nil {
+		return err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return err
+	}
+
+	for _, key := range known {
+		delete(all, key)
+	}
+
+	*extra = nil
+	if len(all) > 0 {
+		*extra = all
+	}
+
+	return nil
+}
+
+// marshalWithExtra encodes v with the extra keys, keys of v take precedence over extra keys.
+func marshalWithExtra(v any, extra map[string]json.RawMessage) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return b, err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+
+	for key, value := range extra {
+		if _, ok := all[key]; !ok {
+			all[key] = value
+		}
+	}
+
+	return json.Marshal(all)
+}
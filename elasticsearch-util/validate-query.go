@@ -0,0 +1,66 @@
+package esutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+type ValidateQueryResponse struct {
+	Valid        bool                       `json:"valid"`
+	Error        string                     `json:"error,omitempty"`
+	Explanations []ValidateQueryExplanation `json:"explanations,omitempty"`
+}
+
+type ValidateQueryExplanation struct {
+	Index       string `json:"index"`
+	Valid       bool   `json:"valid"`
+	Error       string `json:"error,omitempty"`
+	Explanation string `json:"explanation,omitempty"`
+}
+
+// InvalidQueryError is returned when elasticsearch reports the validated query as invalid.
+type InvalidQueryError struct {
+	Reasons []string
+}
+
+func (e InvalidQueryError) Error() string {
+	if len(e.Reasons) == 0 {
+		return "invalid query"
+	}
+
+	return fmt.Sprintf("invalid query, %s", strings.Join(e.Reasons, "; "))
+}
+
+// ParseValidateQuery parses the response of `_validate/query`, returning InvalidQueryError with
+// the reported errors if the query is invalid.
+func ParseValidateQuery(res *esapi.Response) error {
+	defer res.Body.Close()
+	if res.IsError() {
+		return ParseCommonError(res.Body)
+	}
+
+	var resp ValidateQueryResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return err
+	}
+
+	if resp.Valid {
+		return nil
+	}
+
+	e := InvalidQueryError{}
+	if resp.Error != "" {
+		e.Reasons = append(e.Reasons, resp.Error)
+	}
+
+	for _, exp := range resp.Explanations {
+		if !exp.Valid && exp.Error != "" {
+			e.Reasons = append(e.Reasons, fmt.Sprintf("%s: %s", exp.Index, exp.Error))
+		}
+	}
+
+	return e
+}
@@ -0,0 +1,83 @@
+package esutil
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+func TestParseValidateQuery(t *testing.T) {
+	for _, c := range []struct {
+		b      []byte
+		status int
+		err    string
+	}{
+		{
+			b: []byte(`{
+				"_shards": {
+					"total": 1,
+					"successful": 1,
+					"failed": 0
+				},
+				"valid": true,
+				"explanations": [
+					{
+						"index": "test-index",
+						"valid": true,
+						"explanation": "+tenant:foo"
+					}
+				]
+			}`),
+			status: 200,
+		},
+		{
+			b: []byte(`{
+				"_shards": {
+					"total": 1,
+					"successful": 1,
+					"failed": 0
+				},
+				"valid": false,
+				"explanations": [
+					{
+						"index": "test-index",
+						"valid": false,
+						"error": "[test-index/some-uuid] QueryShardException[failed to create query: For input string: \"foo\"]"
+					}
+				]
+			}`),
+			status: 200,
+			err:    "invalid query, test-index: [test-index/some-uuid] QueryShardException[failed to create query: For input string: \"foo\"]",
+		},
+		{
+			b: []byte(`{
+				"valid": false,
+				"error": "ParsingException[unknown query [termz]]"
+			}`),
+			status: 200,
+			err:    "invalid query, ParsingException[unknown query [termz]]",
+		},
+	} {
+		t.Run("test parse validate query", func(t *testing.T) {
+			res := &esapi.Response{
+				StatusCode: c.status,
+				Body:       io.NopCloser(bytes.NewReader(c.b)),
+			}
+
+			err := ParseValidateQuery(res)
+			if c.err == "" {
+				if err != nil {
+					t.Errorf("expecting no error, got %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != c.err {
+				t.Errorf("expecting error '%s', got '%v'", c.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package syncer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+const checkpointSaveInterval = 5 * time.Second
+
+// Checkpoint is the sync position of an index, persisted in the state file. Sort is the sort values
+// of the last document acknowledged by the destination per point-in-time slice, where every document
+// read before it in the slice is acknowledged as well, it's used as search_after parameter when
+// resuming.
+type Checkpoint struct {
+	TimeField string    `json:"time_field"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Slices    int       `json:"slices"`
+	Sort      [][]any   `json:"sort,omitempty"`
+	Written   int       `json:"written"`
+	Complete  bool      `json:"complete"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type checkpointState struct {
+	Indices map[string]Checkpoint `json:"indices"`
+}
+
+type checkpointStore struct {
+	path string
+
+	mu    sync.Mutex
+	dirty bool
+	state checkpointState
+}
+
+// loadCheckpointStore reads the state file at path, a missing state file is treated as empty.
+func loadCheckpointStore(path string) (*checkpointStore, error) {
+	s := &checkpointStore{
+		path: path,
+		state: checkpointState{
+			Indices: map[string]Checkpoint{},
+		},
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	if err := dec.Decode(&s.state); err != nil {
+		return nil, fmt.Errorf("can not decode state file '%s', %s", path, err.Error())
+	}
+
+	if s.state.Indices == nil {
+		s.state.Indices = map[string]Checkpoint{}
+	}
+
+	return s, nil
+}
+
+func (s *checkpointStore) get(index string) (Checkpoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, ok := s.state.Indices[index]
+	return cp, ok
+}
+
+func (s *checkpointStore) set(index string, cp Checkpoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp.UpdatedAt = time.Now().UTC()
+	s.state.Indices[index] = cp
+	s.dirty = true
+}
+
+// save writes the state file if any checkpoint changed since the last save. The state is written to
+// a temporary file first, and renamed, so an interrupted save never leaves a truncated state file.
+func (s *checkpointStore) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	s.dirty = false
+	return nil
+}
+
+// autosave saves the state file every interval until the returned stop function is called.
+func (s *checkpointStore) autosave(interval time.Duration, logger Logger) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := s.save(); err != nil {
+					logger.Errorf("failed to save state file '%s', %s", s.path, err.Error())
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// checkpointTracker advances the checkpoint of an index. Documents are queued in read order of their
+// slice, and acknowledged in any order by the bulk indexer, the checkpoint of a slice only advances
+// over documents that are acknowledged and preceded by acknowledged documents only. A failed document
+// holds the checkpoint of its slice back, so it's read again when resuming.
+type checkpointTracker struct {
+	index string
+	store *checkpointStore
+
+	mu         sync.Mutex
+	checkpoint Checkpoint
+	pending    [][]string
+	slices     map[string]int
+	sorts      map[string][]any
+	acked      map[string]bool
+	finished   bool
+}
+
+func newCheckpointTracker(store *checkpointStore, index string, cp Checkpoint) *checkpointTracker {
+	if cp.Slices < 1 {
+		cp.Slices = 1
+	}
+
+	if len(cp.Sort) != cp.Slices {
+		cp.Sort = make([][]any, cp.Slices)
+	}
+
+	store.set(index, cp)
+	return &checkpointTracker{
+		index:      index,
+		store:      store,
+		checkpoint: cp,
+		pending:    make([][]string, cp.Slices),
+		slices:     map[string]int{},
+		sorts:      map[string][]any{},
+		acked:      map[string]bool{},
+	}
+}
+
+func (t *checkpointTracker) queue(slice int, doc util.Document) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[slice] = append(t.pending[slice], doc.ID)
+	t.slices[doc.ID] = slice
+	t.sorts[doc.ID] = doc.Sort
+}
+
+func (t *checkpointTracker) ack(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	slice, ok := t.slices[id]
+	if !ok {
+		return
+	}
+
+	t.acked[id] = true
+	t.advance(slice)
+}
+
+// finish marks the index as completely read, the checkpoint is complete once every queued document
+// is acknowledged.
+func (t *checkpointTracker) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.finished = true
+	t.advance(0)
+}
+
+// advance must be called under lock.
+func (t *checkpointTracker) advance(slice int) {
+	pending := t.pending[slice]
+	n := 0
+	for n < len(pending) && t.acked[pending[n]] {
+		id := pending[n]
+		t.checkpoint.Sort[slice] = t.sorts[id]
+		delete(t.slices, id)
+		delete(t.sorts, id)
+		delete(t.acked, id)
+		n++
+	}
+
+	t.pending[slice] = pending[n:]
+	complete := t.finished && len(t.slices) == 0
+	if n == 0 && complete == t.checkpoint.Complete {
+		return
+	}
+
+	t.checkpoint.Written += n
+	t.checkpoint.Complete = complete
+	t.store.set(t.index, t.checkpoint)
+}
+
+// written returns the number of documents acknowledged, including the ones acknowledged before
+// resuming.
+func (t *checkpointTracker) written() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return int64(t.checkpoint.Written)
+}
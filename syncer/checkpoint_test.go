@@ -0,0 +1,95 @@
+package syncer
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+func TestCheckpointTracker(t *testing.T) {
+	store, err := loadCheckpointStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := newCheckpointTracker(store, "test-index", Checkpoint{TimeField: "@timestamp", Slices: 2})
+	for _, id := range []string{"a", "b", "c"} {
+		tracker.queue(0, util.Document{
+			DocumentMetadata: util.DocumentMetadata{Index: "test-index", ID: id},
+			SortMetadata:     util.SortMetadata{Sort: []any{json.Number("1672531200000"), id}},
+		})
+	}
+
+	tracker.queue(1, util.Document{
+		DocumentMetadata: util.DocumentMetadata{Index: "test-index", ID: "d"},
+		SortMetadata:     util.SortMetadata{Sort: []any{json.Number("1672531200000"), "d"}},
+	})
+
+	// acknowledging out of order must not advance over unacknowledged documents.
+	tracker.ack("b")
+	if cp, _ := store.get("test-index"); len(cp.Sort[0]) != 0 || cp.Written != 0 {
+		t.Errorf("expecting checkpoint to not advance, got %v with %d written", cp.Sort, cp.Written)
+	}
+
+	tracker.ack("a")
+	cp, _ := store.get("test-index")
+	if cp.Written != 2 || cp.Sort[0][1] != "b" {
+		t.Errorf("expecting checkpoint at 'b' with 2 written, got %v with %d written", cp.Sort, cp.Written)
+	}
+
+	// slices advance independently.
+	tracker.ack("d")
+	cp, _ = store.get("test-index")
+	if cp.Written != 3 || cp.Sort[1][1] != "d" || cp.Sort[0][1] != "b" {
+		t.Errorf("expecting checkpoint at 'b' and 'd' with 3 written, got %v with %d written", cp.Sort, cp.Written)
+	}
+
+	tracker.finish()
+	if cp, _ := store.get("test-index"); cp.Complete {
+		t.Error("expecting checkpoint to not be complete with pending documents")
+	}
+
+	tracker.ack("c")
+	if cp, _ := store.get("test-index"); !cp.Complete || cp.Written != 4 {
+		t.Errorf("expecting complete checkpoint with 4 written, got complete %t with %d written", cp.Complete, cp.Written)
+	}
+}
+
+func TestCheckpointStoreSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store, err := loadCheckpointStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.set("test-index", Checkpoint{
+		TimeField: "event.created",
+		Slices:    1,
+		Sort:      [][]any{{json.Number("1672531200000000001"), "some-id"}},
+		Written:   10,
+	})
+
+	if err := store.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadCheckpointStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cp, ok := loaded.get("test-index")
+	if !ok {
+		t.Fatal("expecting checkpoint of 'test-index' to be persisted")
+	}
+
+	if cp.Sort[0][0] != json.Number("1672531200000000001") {
+		t.Errorf("expecting sort value to keep its precision, got %v", cp.Sort[0][0])
+	}
+
+	if cp.Written != 10 || cp.TimeField != "event.created" {
+		t.Errorf("unexpected checkpoint %+v", cp)
+	}
+}
@@ -8,16 +8,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/elastic/go-elasticsearch/v7"
-	"github.com/elastic/go-elasticsearch/v7/estransport"
 	"github.com/elastic/go-elasticsearch/v7/esutil"
 	"golang.org/x/sync/errgroup"
 
@@ -26,28 +24,174 @@ import (
 
 const (
 	defaultReadAllInterval = 24 * time.Hour
-	paginateLimit          = 100
+	defaultPageSize        = 1000
 	pointInTimeKeepAlive   = "1m"
+	scrollKeepAlive        = time.Minute
+)
+
+const (
+	// ReadModeAuto reads with point-in-time if the source cluster supports it and the index has a
+	// time field, with pagination if it doesn't have a time field, and with scroll on clusters
+	// without point-in-time support.
+	ReadModeAuto     = "auto"
+	ReadModePIT      = "pit"
+	ReadModeScroll   = "scroll"
+	ReadModePaginate = "paginate"
 )
 
 var (
 	// ErrNoReadIndex is error returned when trying to read from elasticsearch without specifying any index name.
 	ErrNoReadIndex = errors.New("no read index specified")
+
+	// ErrUnknownReadMode is error returned when configuring client with an unknown read mode.
+	ErrUnknownReadMode = errors.New("unknown read mode")
+
+	// ErrNoTimeField is error returned when the configured time field doesn't exist in the index mappings.
+	ErrNoTimeField = errors.New("time field not found")
 )
 
 type readAllRequest struct {
-	from  time.Time
-	to    time.Time
-	limit int
-	index string
+	from      time.Time
+	to        time.Time
+	limit     int
+	index     string
+	timeField string
+
+	// filter is a user supplied query DSL, and queryString a user supplied lucene query, both
+	// combined with the time range to select documents to read.
+	filter      json.RawMessage
+	queryString string
+
+	// sourceIncludes and sourceExcludes are the `_source` fields to include and exclude from the
+	// read documents, wildcards are supported.
+	sourceIncludes []string
+	sourceExcludes []string
+
+	// seqNoPrimaryTerm requests the `_seq_no` and `_primary_term` of read documents, which older
+	// clusters don't support.
+	seqNoPrimaryTerm bool
+
+	// size is the number of documents read per page.
+	size int
+	// slices is the number of slices the point-in-time is split into and read concurrently,
+	// slice is the slice being read.
+	slices int
+	slice  int
+
+	// after is the search_after position to start reading from per slice, only used on
+	// point-in-time reads.
+	after [][]any
+	// onQueue is called with every document in read order of its slice, before onRead is
+	// called concurrently.
+	onQueue func(slice int, doc util.Document)
 }
 
 func (r readAllRequest) clone(index string) readAllRequest {
-	return readAllRequest{
-		from:  r.from,
-		to:    r.to,
-		limit: r.limit,
-		index: index,
+	req := r
+	req.index = index
+	return req
+}
+
+// query returns the bool query matching the documents to read, filtered by the time field if
+// there's one, and by the user supplied query.
+func (r readAllRequest) query() map[string]any {
+	filters := []any{}
+	if r.timeField != "" && !(r.from.IsZero() && r.to.IsZero()) {
+		timeRange := map[string]any{
+			"format": "epoch_millis",
+		}
+
+		if !r.from.IsZero() {
+			timeRange["gte"] = r.from.UnixMilli()
+		}
+
+		if !r.to.IsZero() {
+			timeRange["lte"] = r.to.UnixMilli()
+		}
+
+		filters = append(filters, map[string]any{
+			"range": map[string]any{
+				r.timeField: timeRange,
+			},
+		})
+	}
+
+	if len(r.filter) > 0 {
+		filters = append(filters, r.filter)
+	}
+
+	var must any = map[string]any{
+		"match_all": map[string]string{},
+	}
+
+	if r.queryString != "" {
+		must = map[string]any{
+			"query_string": map[string]string{
+				"query": r.queryString,
+			},
+		}
+	}
+
+	return map[string]any{
+		"bool": map[string]any{
+			"must":   must,
+			"filter": filters,
+		},
+	}
+}
+
+// sort returns the sort on the time field and `_id` descendingly, the sort values of the
+// last document are used as search_after parameter.
+func (r readAllRequest) sort() []map[string]string {
+	if r.timeField == "" {
+		return []map[string]string{
+			{"_id": "desc"},
+		}
+	}
+
+	return []map[string]string{
+		{r.timeField: "desc"},
+		{"_id": "desc"},
+	}
+}
+
+// sliceAfter returns the search_after position to start reading the slice from.
+func (r readAllRequest) sliceAfter() []any {
+	if r.slice < len(r.after) {
+		return r.after[r.slice]
+	}
+
+	return nil
+}
+
+// slicing adds the slice parameter to the search body if the point-in-time is read in slices.
+func (r readAllRequest) slicing(body map[string]any) {
+	if r.slices > 1 {
+		body["slice"] = map[string]int{
+			"id":  r.slice,
+			"max": r.slices,
+		}
+	}
+}
+
+// fetchOptions adds the `_source` filtering and document metadata parameters to the search body.
+func (r readAllRequest) fetchOptions(body map[string]any) {
+	body["version"] = true
+	if r.seqNoPrimaryTerm {
+		body["seq_no_primary_term"] = true
+	}
+
+	if len(r.sourceIncludes) > 0 || len(r.sourceExcludes) > 0 {
+		source := map[string][]string{}
+		if len(r.sourceIncludes) > 0 {
+			source["includes"] = r.sourceIncludes
+		}
+
+		if len(r.sourceExcludes) > 0 {
+			source["excludes"] = r.sourceExcludes
+		}
+
+		body["_source"] = source
 	}
 }
 
@@ -60,6 +204,10 @@ func (r readAllRequest) validate() error {
 }
 
 func (r *readAllRequest) setDefaults() {
+	if r.size == 0 {
+		r.size = defaultPageSize
+	}
+
 	if r.limit == 0 {
 		now := time.Now().UTC()
 		if r.to.IsZero() {
@@ -78,6 +226,9 @@ type readClientConfig struct {
 	password     string
 	logRequests  bool
 	logResponses bool
+	readMode     string
+	metrics      *Metrics
+	logger       Logger
 }
 
 func (r readClientConfig) validate() error {
@@ -85,10 +236,29 @@ func (r readClientConfig) validate() error {
 		return ErrNoHost
 	}
 
+	switch r.readMode {
+	case ReadModeAuto, ReadModePIT, ReadModeScroll, ReadModePaginate:
+	default:
+		return ErrUnknownReadMode
+	}
+
 	return nil
 }
 
+func (r *readClientConfig) setDefaults() {
+	if r.readMode == "" {
+		r.readMode = ReadModeAuto
+	}
+
+	r.logger = loggerOrDefault(r.logger)
+}
+
 func newReadClient(cfg readClientConfig) (*readClient, error) {
+	cfg.setDefaults()
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	tr := http.DefaultTransport.(*http.Transport).Clone()
 	tr.TLSClientConfig = &tls.Config{
 		InsecureSkipVerify: true,
@@ -102,10 +272,10 @@ func newReadClient(cfg readClientConfig) (*readClient, error) {
 	}
 
 	if cfg.logRequests || cfg.logResponses {
-		escfg.Logger = &estransport.TextLogger{
-			Output:             os.Stdout,
-			EnableRequestBody:  cfg.logRequests,
-			EnableResponseBody: cfg.logResponses,
+		escfg.Logger = &transportLogger{
+			logger:       cfg.logger,
+			requestBody:  cfg.logRequests,
+			responseBody: cfg.logResponses,
 		}
 	}
 
@@ -116,13 +286,61 @@ func newReadClient(cfg readClientConfig) (*readClient, error) {
 	}
 
 	return &readClient{
-		cl: cl,
+		cl:       cl,
+		readMode: cfg.readMode,
+		metrics:  cfg.metrics,
+		logger:   cfg.logger,
 	}, nil
 }
 
 type readClient struct {
-	cl *elasticsearch.Client
-	wg sync.WaitGroup
+	cl       *elasticsearch.Client
+	wg       sync.WaitGroup
+	readMode string
+	metrics  *Metrics
+	logger   Logger
+
+	infoOnce sync.Once
+	info     util.ClusterInfo
+	infoErr  error
+}
+
+// Info returns the source cluster info, it's only requested once.
+func (r *readClient) Info(ctx context.Context) (util.ClusterInfo, error) {
+	r.infoOnce.Do(func() {
+		res, err := r.cl.Info(r.cl.Info.WithContext(ctx))
+		if err != nil {
+			r.infoErr = err
+			return
+		}
+
+		r.info, r.infoErr = util.ParseInfo(res)
+	})
+
+	return r.info, r.infoErr
+}
+
+// ReadMode returns the read mode used to read an index with the time field, which is either forced by
+// configuration, or chosen from the source cluster version and the time field.
+func (r *readClient) ReadMode(ctx context.Context, timeField string) (string, error) {
+	if r.readMode != ReadModeAuto {
+		return r.readMode, nil
+	}
+
+	info, err := r.Info(ctx)
+	if err != nil {
+		return "", fmt.Errorf("can not get source cluster info, %s", err.Error())
+	}
+
+	if !info.SupportsPointInTime() {
+		return ReadModeScroll, nil
+	}
+
+	if timeField != "" {
+		return ReadModePIT, nil
+	}
+
+	return ReadModePaginate, nil
 }
 
 func (r *readClient) ReadIndexSettings(ctx context.Context, index string) ([]util.IndexSetting, error) {
@@ -140,26 +358,34 @@ func (r *readClient) ReadIndexSettings(ctx context.Context, index string) ([]uti
 	return settings, nil
 }
 
-func (r *readClient) hasTimestamp(ctx context.Context, index string) (bool, error) {
-	settings, err := r.ReadIndexSettings(ctx, index)
-	if err != nil {
-		return false, err
-	}
+// defaultTimeFields are the time fields looked up, in order, when no time field is configured.
+var defaultTimeFields = []string{"@timestamp", "timestamp", "event.created"}
+
+// resolveTimeField returns the `date` or `date_nanos` field used to sort and filter the index,
+// or an empty string if the index doesn't have one. A configured time field must be a date field
+// of the mappings, otherwise an error is returned rather than reading the index unfiltered. Without
+// configured time field, the well-known time fields and then the first date field found in the
+// mappings are used.
+func resolveTimeField(mappings util.Mappings, preferred string) (string, error) {
+	if preferred != "" {
+		if p, ok := mappings.Property(preferred); ok && p.IsDate() {
+			return preferred, nil
+		}
 
-	for _, setting := range settings {
-		if setting.Index == index {
-			m, ok := setting.Setting.Mappings.Properties["timestamp"]
-			if !ok {
-				return false, nil
-			}
+		return "", fmt.Errorf("%s, '%s' is not a date field of the index mappings", ErrNoTimeField.Error(), preferred)
+	}
 
-			if m.Type == "date" {
-				return true, nil
-			}
+	for _, field := range defaultTimeFields {
+		if p, ok := mappings.Property(field); ok && p.IsDate() {
+			return field, nil
 		}
 	}
 
-	return false, nil
+	if fields := mappings.DateFields(); len(fields) > 0 {
+		return fields[0], nil
+	}
+
+	return "", nil
 }
 
 func (r *readClient) createPIT(ctx context.Context, index string) (string, error) {
@@ -168,10 +394,17 @@ func (r *readClient) createPIT(ctx context.Context, index string) (string, error
 		return "", err
 	}
 
-	return util.ParseOpenPIT(res)
+	pit, err := util.ParseOpenPIT(res)
+	if err != nil {
+		return "", err
+	}
+
+	r.metrics.addOpenPIT(1)
+	return pit, nil
 }
 
 func (r *readClient) closePIT(ctx context.Context, pit string) error {
+	defer r.metrics.addOpenPIT(-1)
 	b, err := util.PointInTime{ID: pit}.Parse()
 	if err != nil {
 		return err
@@ -191,6 +424,7 @@ func (r *readClient) searchAllPIT(ctx context.Context, req readAllRequest, pit s
 	}
 
 	res, err := r.cl.Search(
+		r.cl.Search.WithContext(ctx),
 		r.cl.Search.WithBody(body),
 	)
 
@@ -202,37 +436,17 @@ func (r *readClient) searchAllPIT(ctx context.Context, req readAllRequest, pit s
 }
 
 func (r *readClient) searchAllPITBody(req readAllRequest, pit string) (io.Reader, error) {
-	filters := []map[string]any{}
-	if req.from.IsZero() && req.to.IsZero() {
-		filters = append(filters, map[string]any{
-			"range": map[string]any{
-				"timestamp": map[string]any{
-					"gte":    req.from.UnixMilli(),
-					"lte":    req.to.UnixMilli(),
-					"format": "epoch_millis",
-				},
-			},
-		})
-	}
-
 	query := map[string]any{
-		"query": map[string]any{
-			"bool": map[string]any{
-				"must": map[string]any{
-					"match_all": map[string]string{},
-				},
-				"filter": filters,
-			},
-		},
+		"size":  req.size,
+		"query": req.query(),
 		"pit": map[string]string{
 			"id":         pit,
 			"keep_alive": pointInTimeKeepAlive,
 		},
-		"sort": []map[string]string{
-			{"timestamp": "desc"},
-			{"_id": "desc"},
-		},
+		"sort": req.sort(),
 	}
+	req.slicing(query)
+	req.fetchOptions(query)
 
 	b, err := json.Marshal(query)
 	if err != nil {
@@ -249,6 +463,7 @@ func (r *readClient) searchAllAfterPIT(ctx context.Context, req readAllRequest,
 	}
 
 	res, err := r.cl.Search(
+		r.cl.Search.WithContext(ctx),
 		r.cl.Search.WithBody(body),
 	)
 
@@ -260,38 +475,18 @@ func (r *readClient) searchAllAfterPIT(ctx context.Context, req readAllRequest,
 }
 
 func (r *readClient) searchAllAfterBodyPIT(req readAllRequest, pit string, last util.SortMetadata) (io.Reader, error) {
-	filters := []map[string]any{}
-	if req.from.IsZero() && req.to.IsZero() {
-		filters = append(filters, map[string]any{
-			"range": map[string]any{
-				"timestamp": map[string]any{
-					"gte":    req.from.UnixMilli(),
-					"lte":    req.to.UnixMilli(),
-					"format": "epoch_millis",
-				},
-			},
-		})
-	}
-
 	query := map[string]any{
-		"query": map[string]any{
-			"bool": map[string]any{
-				"must": map[string]any{
-					"match_all": map[string]string{},
-				},
-				"filter": filters,
-			},
-		},
+		"size":  req.size,
+		"query": req.query(),
 		"pit": map[string]string{
 			"id":         pit,
 			"keep_alive": pointInTimeKeepAlive,
 		},
 		"search_after": last.Sort,
-		"sort": []map[string]string{
-			{"timestamp": "desc"},
-			{"_id": "desc"},
-		},
+		"sort":         req.sort(),
 	}
+	req.slicing(query)
+	req.fetchOptions(query)
 
 	b, err := json.Marshal(query)
 	if err != nil {
@@ -307,9 +502,43 @@ func (r *readClient) readAllPIT(ctx context.Context, req readAllRequest, onRead
 		return fmt.Errorf("can not create point-in-time, %s", err.Error())
 	}
 
-	docs, err := r.searchAllPIT(ctx, req, pit)
-	count := 0
-	for len(docs) >= 0 {
+	defer func() {
+		if err := r.closePIT(context.Background(), pit); err != nil {
+			r.logger.With("index", req.index).Warnf("failed to close point-in-time, %s", err.Error())
+		}
+	}()
+
+	count := new(int64)
+	if req.slices <= 1 {
+		return r.readPITSlice(ctx, req, pit, count, onRead)
+	}
+
+	r.logger.With("index", req.index).Infof("reading index in %d slices", req.slices)
+	g := new(errgroup.Group)
+	for i := 0; i < req.slices; i++ {
+		req := req
+		req.slice = i
+		g.Go(func() error {
+			return r.readPITSlice(ctx, req, pit, count, onRead)
+		})
+	}
+
+	return g.Wait()
+}
+
+// readPITSlice reads a slice of the point-in-time with search_after until there's no result returned,
+// count is shared by every slice of the index to apply the limit.
+func (r *readClient) readPITSlice(ctx context.Context, req readAllRequest, pit string, count *int64, onRead func(doc util.Document)) error {
+	var docs []util.Document
+	var err error
+	if after := req.sliceAfter(); len(after) > 0 {
+		r.logger.With("index", req.index, "slice", req.slice).Infof("resuming point-in-time read after %v", after)
+		docs, err = r.searchAllAfterPIT(ctx, req, pit, util.SortMetadata{Sort: after})
+	} else {
+		docs, err = r.searchAllPIT(ctx, req, pit)
+	}
+
+	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -317,7 +546,7 @@ func (r *readClient) readAllPIT(ctx context.Context, req readAllRequest, onRead
 		}
 
 		// return early if limit is reached.
-		if req.limit != 0 && count >= req.limit {
+		if req.limit != 0 && atomic.LoadInt64(count) >= int64(req.limit) {
 			return nil
 		}
 
@@ -325,8 +554,16 @@ func (r *readClient) readAllPIT(ctx context.Context, req readAllRequest, onRead
 			return fmt.Errorf("can not read all on index '%s', %s", req.index, err.Error())
 		}
 
-		count = count + len(docs)
+		if len(docs) == 0 {
+			return nil
+		}
+
+		atomic.AddInt64(count, int64(len(docs)))
 		for _, doc := range docs {
+			if req.onQueue != nil {
+				req.onQueue(req.slice, doc)
+			}
+
 			r.wg.Add(1)
 			go func(doc util.Document) {
 				defer r.wg.Done()
@@ -334,20 +571,14 @@ func (r *readClient) readAllPIT(ctx context.Context, req readAllRequest, onRead
 			}(doc)
 		}
 
-		if len(docs) == 0 {
-			break
-		}
-
 		docs, err = r.searchAllAfterPIT(ctx, req, pit, docs[len(docs)-1].SortMetadata)
 	}
-
-	return nil
 }
 
 func (r *readClient) readAllPaginate(ctx context.Context, req readAllRequest, onRead func(doc util.Document)) error {
-	docs, total, err := r.searchLimitOffset(ctx, req, paginateLimit, 0)
+	docs, total, err := r.searchLimitOffset(ctx, req, req.size, 0)
 	count, page := 0, 0
-	for len(docs) >= 0 && total != 0 {
+	for total != 0 {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -363,6 +594,10 @@ func (r *readClient) readAllPaginate(ctx context.Context, req readAllRequest, on
 			return fmt.Errorf("can not read paginate, %s", err.Error())
 		}
 
+		if len(docs) == 0 {
+			return nil
+		}
+
 		count = count + len(docs)
 		for _, doc := range docs {
 			r.wg.Add(1)
@@ -372,10 +607,145 @@ func (r *readClient) readAllPaginate(ctx context.Context, req readAllRequest, on
 			}(doc)
 		}
 
-		docs, total, err = r.searchLimitOffset(ctx, req, paginateLimit, page*paginateLimit)
+		page++
+		docs, total, err = r.searchLimitOffset(ctx, req, req.size, page*req.size)
 	}
 
-	return nil
+	return err
+}
+
+func (r *readClient) readAllScroll(ctx context.Context, req readAllRequest, onRead func(doc util.Document)) error {
+	count := new(int64)
+	if req.slices <= 1 {
+		return r.readScrollSlice(ctx, req, count, onRead)
+	}
+
+	r.logger.With("index", req.index).Infof("reading index in %d slices", req.slices)
+	g := new(errgroup.Group)
+	for i := 0; i < req.slices; i++ {
+		req := req
+		req.slice = i
+		g.Go(func() error {
+			return r.readScrollSlice(ctx, req, count, onRead)
+		})
+	}
+
+	return g.Wait()
+}
+
+// readScrollSlice reads a slice of the index with scroll until there's no result returned, count is
+// shared by every slice of the index to apply the limit.
+func (r *readClient) readScrollSlice(ctx context.Context, req readAllRequest, count *int64, onRead func(doc util.Document)) error {
+	page, err := r.searchScroll(ctx, req)
+	if err != nil {
+		return fmt.Errorf("can not read scroll on index '%s', %s", req.index, err.Error())
+	}
+
+	scrollID := page.ScrollID
+	defer func() {
+		if err := r.clearScroll(context.Background(), scrollID); err != nil && err != util.ErrScrollNotFound {
+			r.logger.With("index", req.index).Warnf("failed to clear scroll, %s", err.Error())
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		// return early if limit is reached.
+		if req.limit != 0 && atomic.LoadInt64(count) >= int64(req.limit) {
+			return nil
+		}
+
+		if len(page.Results) == 0 {
+			return nil
+		}
+
+		atomic.AddInt64(count, int64(len(page.Results)))
+		for _, doc := range page.Results {
+			r.wg.Add(1)
+			go func(doc util.Document) {
+				defer r.wg.Done()
+				onRead(doc)
+			}(doc)
+		}
+
+		page, err = r.scroll(ctx, scrollID)
+		if err != nil {
+			return fmt.Errorf("can not read scroll on index '%s', %s", req.index, err.Error())
+		}
+
+		if page.ScrollID != "" {
+			scrollID = page.ScrollID
+		}
+	}
+}
+
+func (r *readClient) searchScroll(ctx context.Context, req readAllRequest) (util.ScrollPage, error) {
+	body, err := r.searchScrollBody(req)
+	if err != nil {
+		return util.ScrollPage{}, err
+	}
+
+	res, err := r.cl.Search(
+		r.cl.Search.WithContext(ctx),
+		r.cl.Search.WithIndex(req.index),
+		r.cl.Search.WithBody(body),
+		r.cl.Search.WithScroll(scrollKeepAlive),
+	)
+
+	if err != nil {
+		return util.ScrollPage{}, err
+	}
+
+	return util.ParseScroll(res)
+}
+
+func (r *readClient) searchScrollBody(req readAllRequest) (io.Reader, error) {
+	query := map[string]any{
+		"size":  req.size,
+		"query": req.query(),
+		"sort":  []string{"_doc"},
+	}
+	req.slicing(query)
+	req.fetchOptions(query)
+
+	b, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+func (r *readClient) scroll(ctx context.Context, scrollID string) (util.ScrollPage, error) {
+	res, err := r.cl.Scroll(
+		r.cl.Scroll.WithContext(ctx),
+		r.cl.Scroll.WithScrollID(scrollID),
+		r.cl.Scroll.WithScroll(scrollKeepAlive),
+	)
+
+	if err != nil {
+		return util.ScrollPage{}, err
+	}
+
+	return util.ParseScroll(res)
+}
+
+func (r *readClient) clearScroll(ctx context.Context, scrollID string) error {
+	res, err := r.cl.ClearScroll(
+		r.cl.ClearScroll.WithContext(ctx),
+		r.cl.ClearScroll.WithScrollID(scrollID),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return util.ParseClearScroll(res)
 }
 
 func (r *readClient) searchLimitOffset(ctx context.Context, req readAllRequest, limit, offset int) ([]util.Document, int, error) {
@@ -385,6 +755,7 @@ func (r *readClient) searchLimitOffset(ctx context.Context, req readAllRequest,
 	}
 
 	res, err := r.cl.Search(
+		r.cl.Search.WithContext(ctx),
 		r.cl.Search.WithIndex(req.index),
 		r.cl.Search.WithBody(body),
 	)
@@ -402,35 +773,13 @@ func (r *readClient) searchLimitOffset(ctx context.Context, req readAllRequest,
 }
 
 func (r *readClient) searchLimitOffsetBody(req readAllRequest, limit int, offset int) (io.Reader, error) {
-	filters := []map[string]any{}
-	if req.from.IsZero() && req.to.IsZero() {
-		filters = append(filters, map[string]any{
-			"range": map[string]any{
-				"timestamp": map[string]any{
-					"gte":    req.from.UnixMilli(),
-					"lte":    req.to.UnixMilli(),
-					"format": "epoch_millis",
-				},
-			},
-		})
-	}
-
 	query := map[string]any{
-		"from": offset,
-		"size": limit,
-		"query": map[string]any{
-			"bool": map[string]any{
-				"must": map[string]any{
-					"match_all": map[string]string{},
-				},
-				"filter": filters,
-			},
-		},
-		"sort": []map[string]string{
-			{"timestamp": "desc"},
-			{"_id": "desc"},
-		},
+		"from":  offset,
+		"size":  limit,
+		"query": req.query(),
+		"sort":  req.sort(),
 	}
+	req.fetchOptions(query)
 
 	b, err := json.Marshal(query)
 	if err != nil {
@@ -446,20 +795,6 @@ func (r *readClient) ReadAll(ctx context.Context, req readAllRequest, onRead fun
 		return err
 	}
 
-	// TODO: add implementation
-	// check if the index mappings contain `timestamp` field with datetime field type.
-
-	// If it does, create a point-in-time for the index, and do match_all query with sort
-	// on field `timestamp` and `_id` descendingly, passing the point-in-time id along the query.
-	// it then do a search_after query, passing the point-in-time id, the last result's timestamp and _id
-	// as search_after parameter, and use the same sort parameter, until there's no result
-	// returned from the query.
-
-	// TODO: add result limit parameter, and/or from-to time limit.
-
-	// If it doesn't, do a simple pagination using from and to search parameter, note that
-	// the result could be inconsistent if a referesh happen during the pagination query.
-
 	settings, err := r.ReadIndexSettings(ctx, req.index)
 	if err != nil {
 		return err
@@ -467,20 +802,9 @@ func (r *readClient) ReadAll(ctx context.Context, req readAllRequest, onRead fun
 
 	g := new(errgroup.Group)
 	for _, setting := range settings {
-		req := req.clone(setting.Index)
+		req, setting := req.clone(setting.Index), setting
 		g.Go(func() error {
-			ok, err := r.hasTimestamp(ctx, req.index)
-			if err != nil {
-				return err
-			}
-
-			if ok {
-				log.Printf("reading all using point-in-time from index '%s'\n", req.index)
-				return r.readAllPIT(ctx, req, onRead)
-			}
-
-			log.Printf("reading all using pagination from index '%s'\n", req.index)
-			return r.readAllPaginate(ctx, req, onRead)
+			return r.ReadIndex(ctx, req, setting, onRead)
 		})
 
 	}
@@ -488,6 +812,160 @@ func (r *readClient) ReadAll(ctx context.Context, req readAllRequest, onRead fun
 	return g.Wait()
 }
 
+// ReadIndex reads all documents of a single index, with the read mode chosen for the index:
+//   - point-in-time, where documents are read with search_after sorted on the time field and `_id`
+//     descendingly, until there's no result returned.
+//   - scroll, for clusters without point-in-time support.
+//   - pagination using from and size, note that the result could be inconsistent if a refresh
+//     happen during the pagination query, and it's capped by `index.max_result_window`.
+func (r *readClient) ReadIndex(ctx context.Context, req readAllRequest, setting util.IndexSetting, onRead func(doc util.Document)) error {
+	req.setDefaults()
+	timeField, err := resolveTimeField(setting.Setting.Mappings, req.timeField)
+	if err != nil {
+		return err
+	}
+
+	req.timeField = timeField
+	mode, err := r.ReadMode(ctx, req.timeField)
+	if err != nil {
+		return err
+	}
+
+	info, err := r.Info(ctx)
+	if err != nil {
+		return err
+	}
+
+	req.seqNoPrimaryTerm = info.SupportsSeqNoPrimaryTerm()
+
+	switch mode {
+	case ReadModePIT:
+		r.logger.With("index", req.index).Infof("reading all using point-in-time sorted on '%s'", req.timeField)
+		return r.readAllPIT(ctx, req, onRead)
+	case ReadModeScroll:
+		r.logger.With("index", req.index).Infof("reading all using scroll")
+		return r.readAllScroll(ctx, req, onRead)
+	}
+
+	r.logger.With("index", req.index).Infof("reading all using pagination")
+	return r.readAllPaginate(ctx, req, onRead)
+}
+
+// ValidateQuery validates the query of the request against the index with `_validate/query`.
+func (r *readClient) ValidateQuery(ctx context.Context, req readAllRequest) error {
+	b, err := json.Marshal(map[string]any{
+		"query": req.query(),
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := r.cl.Indices.ValidateQuery(
+		r.cl.Indices.ValidateQuery.WithContext(ctx),
+		r.cl.Indices.ValidateQuery.WithIndex(req.index),
+		r.cl.Indices.ValidateQuery.WithBody(bytes.NewReader(b)),
+		r.cl.Indices.ValidateQuery.WithExplain(true),
+	)
+	if err != nil {
+		return err
+	}
+
+	return util.ParseValidateQuery(res)
+}
+
+// Count returns the number of documents of the index matching the query.
+func (r *readClient) Count(ctx context.Context, index string, query any) (int64, error) {
+	b, err := json.Marshal(map[string]any{
+		"query": query,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := r.cl.Count(
+		r.cl.Count.WithContext(ctx),
+		r.cl.Count.WithIndex(index),
+		r.cl.Count.WithBody(bytes.NewReader(b)),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return util.ParseCountResponse(res)
+}
+
+// IndexStats returns the primary shards stats of every index matching the index pattern.
+func (r *readClient) IndexStats(ctx context.Context, index string) (map[string]util.IndexStats, error) {
+	res, err := r.cl.Indices.Stats(
+		r.cl.Indices.Stats.WithContext(ctx),
+		r.cl.Indices.Stats.WithIndex(index),
+		r.cl.Indices.Stats.WithMetric("docs", "store"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return util.ParseIndicesStatsResponse(res)
+}
+
+// DateHistogram returns the number of documents of the index matching the query per interval of the
+// time field, buckets without documents are omitted.
+func (r *readClient) DateHistogram(ctx context.Context, index string, query any, timeField, interval string) ([]util.DateHistogramBucket, error) {
+	info, err := r.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// `fixed_interval` replaced `interval` in elasticsearch 7.2.
+	intervalParam := "fixed_interval"
+	if major, minor := info.Version.Major(); info.Version.Distribution == "" && (major < 7 || (major == 7 && minor < 2)) {
+		intervalParam = "interval"
+	}
+
+	b, err := json.Marshal(map[string]any{
+		"size":  0,
+		"query": query,
+		"aggs": map[string]any{
+			dateHistogramAggregation: map[string]any{
+				"date_histogram": map[string]any{
+					"field":         timeField,
+					intervalParam:   interval,
+					"min_doc_count": 1,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.cl.Search(
+		r.cl.Search.WithContext(ctx),
+		r.cl.Search.WithIndex(index),
+		r.cl.Search.WithBody(bytes.NewReader(b)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	agg, err := util.ParseDateHistogramAggregation(res, dateHistogramAggregation)
+	if err != nil {
+		return nil, err
+	}
+
+	return agg.Buckets, nil
+}
+
+// MaxTime returns the latest value of the time field in the index.
+func (r *readClient) MaxTime(ctx context.Context, index, timeField string) (time.Time, bool, error) {
+	return maxTime(ctx, r.cl, index, timeField)
+}
+
+// Wait waits for every onRead callback to return.
+func (r *readClient) Wait() {
+	r.wg.Wait()
+}
+
 // ErrNoHost is error returned when configuring client with no host specified
 var ErrNoHost = errors.New("no elasticsearch host specified")
 
@@ -497,6 +975,8 @@ var (
 	defaultFlushInterval = 30 * time.Second
 )
 
+const versionTypeExternal = "external"
+
 type readWriteClientConfig struct {
 	host     string
 	username string
@@ -508,6 +988,14 @@ type readWriteClientConfig struct {
 	workerNumber  int
 	flushBytes    float64
 	flushInterval time.Duration
+
+	// onConflict is the conflict policy, and externalVersion indexes documents with their source
+	// version as external version.
+	onConflict      string
+	externalVersion bool
+
+	metrics *Metrics
+	logger  Logger
 }
 
 func (rw readWriteClientConfig) validate() error {
@@ -515,10 +1003,22 @@ func (rw readWriteClientConfig) validate() error {
 		return ErrNoHost
 	}
 
+	if !validConflictPolicy(rw.onConflict) {
+		return ErrUnknownConflictPolicy
+	}
+
+	if rw.externalVersion && rw.onConflict != OnConflictOverwrite && rw.onConflict != OnConflictNewerWins {
+		return ErrExternalVersionPolicy
+	}
+
 	return nil
 }
 
 func (rw *readWriteClientConfig) setDefaults() {
+	if rw.onConflict == "" {
+		rw.onConflict = OnConflictOverwrite
+	}
+
 	if rw.workerNumber == 0 {
 		rw.workerNumber = defaultWorkerNumber
 	}
@@ -530,6 +1030,8 @@ func (rw *readWriteClientConfig) setDefaults() {
 	if rw.flushInterval == 0 {
 		rw.flushInterval = defaultFlushInterval
 	}
+
+	rw.logger = loggerOrDefault(rw.logger)
 }
 
 func newReadWriteClient(cfg readWriteClientConfig) (*readWriteClient, error) {
@@ -555,6 +1057,8 @@ func newReadWriteClient(cfg readWriteClientConfig) (*readWriteClient, error) {
 				retryBackoff.Reset()
 			}
 
+			cfg.metrics.retry()
+
 			return retryBackoff.NextBackOff()
 		},
 		MaxRetries: 5,
@@ -562,13 +1066,17 @@ func newReadWriteClient(cfg readWriteClientConfig) (*readWriteClient, error) {
 	}
 
 	if cfg.logRequests || cfg.logResponses {
-		escfg.Logger = &estransport.TextLogger{
-			Output:             os.Stdout,
-			EnableRequestBody:  cfg.logRequests,
-			EnableResponseBody: cfg.logResponses,
+		escfg.Logger = &transportLogger{
+			logger:       cfg.logger,
+			requestBody:  cfg.logRequests,
+			responseBody: cfg.logResponses,
 		}
 	}
 
+	if cfg.metrics != nil {
+		escfg.Transport = metricsTransport{next: tr, metrics: cfg.metrics}
+	}
+
 	es, err := elasticsearch.NewClient(escfg)
 
 	if err != nil {
@@ -585,16 +1093,27 @@ func newReadWriteClient(cfg readWriteClientConfig) (*readWriteClient, error) {
 		return nil, fmt.Errorf("error creating indexer, %s", err.Error())
 	}
 
+	cfg.metrics.setBulkIndexer(bi)
+
 	return &readWriteClient{
-		cl: es,
-		bi: bi,
+		cl:              es,
+		bi:              bi,
+		onConflict:      cfg.onConflict,
+		externalVersion: cfg.externalVersion,
 	}, nil
 }
 
 type readWriteClient struct {
+	// inFlight is the size of the documents added to the bulk indexer and not yet written, it's
+	// first to be 64-bit aligned for atomic operations.
+	inFlight int64
+
 	cl *elasticsearch.Client
 	bi esutil.BulkIndexer
 	wg sync.WaitGroup
+
+	onConflict      string
+	externalVersion bool
 }
 
 func (c *readWriteClient) IndexExist(ctx context.Context, index string) (bool, error) {
@@ -635,42 +1154,190 @@ func (c *readWriteClient) CreateIndex(ctx context.Context, setting util.IndexSet
 	return nil
 }
 
-func (c *readWriteClient) WriteDocument(ctx context.Context, doc util.Document, onSuccess func(util.DocumentMetadata), onError func(util.DocumentMetadata, error)) error {
+// GetMapping returns the current mappings of the index.
+func (c *readWriteClient) GetMapping(ctx context.Context, index string) (util.Mappings, error) {
+	res, err := c.cl.Indices.GetMapping(
+		c.cl.Indices.GetMapping.WithContext(ctx),
+		c.cl.Indices.GetMapping.WithIndex(index),
+	)
+	if err != nil {
+		return util.Mappings{}, err
+	}
+
+	mappings, err := util.ParseGetMappingResponse(res)
+	if err != nil {
+		return util.Mappings{}, err
+	}
+
+	m, ok := mappings[index]
+	if !ok {
+		return util.Mappings{}, fmt.Errorf("no mappings found for index '%s'", index)
+	}
+
+	return m, nil
+}
+
+// PutMapping adds the mappings to the existing mappings of the index.
+func (c *readWriteClient) PutMapping(ctx context.Context, index string, mappings util.Mappings) error {
+	b, err := json.Marshal(mappings)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.cl.Indices.PutMapping(
+		bytes.NewReader(b),
+		c.cl.Indices.PutMapping.WithContext(ctx),
+		c.cl.Indices.PutMapping.WithIndex(index),
+	)
+	if err != nil {
+		return err
+	}
+
+	return util.ParseAcknowledgedResponse(res)
+}
+
+// WriteDocument writes the document according to the conflict policy, the time field is compared
+// by the newer-wins policy. onSuccess is called with the outcome of written documents, including
+// documents skipped by the conflict policy, and onError with documents failed to be written.
+func (c *readWriteClient) WriteDocument(ctx context.Context, doc util.Document, timeField string, onSuccess func(util.DocumentMetadata, WriteResult), onError func(util.DocumentMetadata, error)) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	default:
 	}
 
-	meta := doc.DocumentMetadata
-	body, err := doc.ToReader()
+	item, err := c.bulkItem(doc, timeField)
 	if err != nil {
 		return err
 	}
 
+	return c.add(ctx, doc.DocumentMetadata, item, onSuccess, onError)
+}
+
+// WriteAction writes the document with the bulk action as is, regardless of the conflict policy,
+// the source is the body of the action, e.g. the partial document of an update action.
+func (c *readWriteClient) WriteAction(ctx context.Context, action string, doc util.Document, onSuccess func(util.DocumentMetadata, WriteResult), onError func(util.DocumentMetadata, error)) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	return c.add(ctx, doc.DocumentMetadata, esutil.BulkIndexerItem{
+		Action:     action,
+		DocumentID: doc.ID,
+		Index:      doc.Index,
+		Routing:    doc.Routing,
+		Body:       bytes.NewReader(doc.Source),
+	}, onSuccess, onError)
+}
+
+// add adds the item to the bulk indexer, calling onSuccess or onError once it's written.
+func (c *readWriteClient) add(ctx context.Context, meta util.DocumentMetadata, item esutil.BulkIndexerItem, onSuccess func(util.DocumentMetadata, WriteResult), onError func(util.DocumentMetadata, error)) error {
+	var size int64
+	if body, ok := item.Body.(*bytes.Reader); ok {
+		size = body.Size()
+	}
+
+	item.OnSuccess = func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
+		defer c.wg.Done()
+		atomic.AddInt64(&c.inFlight, -size)
+		onSuccess(meta, writeResult(res))
+	}
+
+	item.OnFailure = func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+		defer c.wg.Done()
+		atomic.AddInt64(&c.inFlight, -size)
+
+		// a conflict creating a document, or indexing with an external version, means the
+		// destination document exists, or is as new or newer, and is kept as is.
+		if err == nil && res.Status == http.StatusConflict && (item.Action == "create" || item.VersionType == versionTypeExternal) {
+			onSuccess(meta, WriteSkipped)
+			return
+		}
+
+		if err == nil {
+			err = util.CommonErrorResponse{
+				Status: res.Status,
+				Err: util.CommonError{
+					Type:   res.Error.Type,
+					Reason: res.Error.Reason,
+					Index:  res.Index,
+				},
+			}
+		}
+
+		onError(meta, err)
+	}
+
+	c.wg.Add(1)
+	atomic.AddInt64(&c.inFlight, size)
+	if err := c.bi.Add(ctx, item); err != nil {
+		atomic.AddInt64(&c.inFlight, -size)
+		c.wg.Done()
+		return err
+	}
+
+	return nil
+}
+
+// DeleteDocument deletes the document, a document which doesn't exist is deleted successfully.
+func (c *readWriteClient) DeleteDocument(ctx context.Context, doc util.DocumentMetadata, onSuccess func(util.DocumentMetadata), onError func(util.DocumentMetadata, error)) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
 	c.wg.Add(1)
-	err = c.bi.Add(ctx, esutil.BulkIndexerItem{
-		Action:     "index",
+	err := c.bi.Add(ctx, esutil.BulkIndexerItem{
+		Action:     "delete",
 		DocumentID: doc.ID,
 		Index:      doc.Index,
-		Body:       body,
+		Routing:    doc.Routing,
 		OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
 			defer c.wg.Done()
-			onSuccess(meta)
+			onSuccess(doc)
 		},
 		OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
 			defer c.wg.Done()
-			onError(meta, err)
+			if err == nil && res.Status == http.StatusNotFound {
+				onSuccess(doc)
+				return
+			}
+
+			if err == nil {
+				err = util.CommonErrorResponse{
+					Status: res.Status,
+					Err: util.CommonError{
+						Type:   res.Error.Type,
+						Reason: res.Error.Reason,
+						Index:  res.Index,
+					},
+				}
+			}
+
+			onError(doc, err)
 		},
 	})
-
 	if err != nil {
+		c.wg.Done()
 		return err
 	}
 
 	return nil
 }
 
+// MaxTime returns the latest value of the time field in the index.
+func (c *readWriteClient) MaxTime(ctx context.Context, index, timeField string) (time.Time, bool, error) {
+	return maxTime(ctx, c.cl, index, timeField)
+}
+
+// InFlightBytes returns the size of the documents waiting to be written.
+func (c *readWriteClient) InFlightBytes() int64 {
+	return atomic.LoadInt64(&c.inFlight)
+}
+
 func (c *readWriteClient) Flush(ctx context.Context) error {
 	c.wg.Add(1)
 	defer c.wg.Done()
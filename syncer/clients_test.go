@@ -0,0 +1,409 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+func TestResolveTimeField(t *testing.T) {
+	mappings := util.Mappings{
+		Properties: map[string]util.MappingProperty{
+			"created_at": {Type: "date"},
+			"event": {
+				Properties: map[string]util.MappingProperty{
+					"created": {Type: "date_nanos"},
+				},
+			},
+			"name": {Type: "keyword"},
+		},
+	}
+
+	for _, c := range []struct {
+		preferred string
+		expected  string
+		err       bool
+	}{
+		{preferred: "", expected: "event.created"},
+		{preferred: "created_at", expected: "created_at"},
+		{preferred: "name", err: true},
+		{preferred: "missing", err: true},
+	} {
+		field, err := resolveTimeField(mappings, c.preferred)
+		if field != c.expected || (err != nil) != c.err {
+			t.Errorf("expecting time field '%s' for '%s', got '%s', %v", c.expected, c.preferred, field, err)
+		}
+	}
+
+	if field, err := resolveTimeField(util.Mappings{}, ""); field != "" || err != nil {
+		t.Errorf("expecting no time field, got '%s', %v", field, err)
+	}
+}
+
+func TestReadAllRequestQuery(t *testing.T) {
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	req := readAllRequest{from: from, to: to, index: "test-index", timeField: "@timestamp"}
+
+	b, err := json.Marshal(map[string]any{"query": req.query(), "sort": req.sort()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body struct {
+		Query struct {
+			Bool struct {
+				Filter []map[string]map[string]map[string]any `json:"filter"`
+			} `json:"bool"`
+		} `json:"query"`
+		Sort []map[string]string `json:"sort"`
+	}
+
+	if err := json.Unmarshal(b, &body); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(body.Query.Bool.Filter) != 1 {
+		t.Fatalf("expecting 1 filter, got %d", len(body.Query.Bool.Filter))
+	}
+
+	r, ok := body.Query.Bool.Filter[0]["range"]["@timestamp"]
+	if !ok {
+		t.Fatal("expecting range filter on '@timestamp'")
+	}
+
+	if r["gte"] != float64(from.UnixMilli()) || r["lte"] != float64(to.UnixMilli()) {
+		t.Errorf("expecting range %d - %d, got %v - %v", from.UnixMilli(), to.UnixMilli(), r["gte"], r["lte"])
+	}
+
+	if len(body.Sort) != 2 || body.Sort[0]["@timestamp"] != "desc" || body.Sort[1]["_id"] != "desc" {
+		t.Errorf("unexpected sort %v", body.Sort)
+	}
+
+	req.timeField = ""
+	if filters := req.query()["bool"].(map[string]any)["filter"].([]any); len(filters) != 0 {
+		t.Errorf("expecting no filter without time field, got %v", filters)
+	}
+}
+
+func TestReadAllRequestUserQuery(t *testing.T) {
+	req := readAllRequest{
+		index:       "test-index",
+		filter:      json.RawMessage(`{"term":{"tenant":"foo"}}`),
+		queryString: "event.type:login",
+	}
+
+	b, err := json.Marshal(req.query())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"bool":{"filter":[{"term":{"tenant":"foo"}}],"must":{"query_string":{"query":"event.type:login"}}}}`
+	if string(b) != expected {
+		t.Errorf("expecting query %s, got %s", expected, b)
+	}
+}
+
+func TestSearchAllPITBodySlice(t *testing.T) {
+	r := &readClient{}
+	for _, c := range []struct {
+		req   readAllRequest
+		slice map[string]int
+	}{
+		{
+			req: readAllRequest{index: "test-index", timeField: "@timestamp", size: 500},
+		},
+		{
+			req:   readAllRequest{index: "test-index", timeField: "@timestamp", size: 500, slices: 4, slice: 2},
+			slice: map[string]int{"id": 2, "max": 4},
+		},
+	} {
+		body, err := r.searchAllPITBody(c.req, "some-pit")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var parsed struct {
+			Size  int            `json:"size"`
+			Slice map[string]int `json:"slice"`
+		}
+
+		if err := json.NewDecoder(body).Decode(&parsed); err != nil {
+			t.Fatal(err)
+		}
+
+		if parsed.Size != c.req.size {
+			t.Errorf("expecting size %d, got %d", c.req.size, parsed.Size)
+		}
+
+		if len(parsed.Slice) != len(c.slice) || parsed.Slice["id"] != c.slice["id"] || parsed.Slice["max"] != c.slice["max"] {
+			t.Errorf("expecting slice %v, got %v", c.slice, parsed.Slice)
+		}
+	}
+}
+
+func TestReadMode(t *testing.T) {
+	for _, c := range []struct {
+		version   string
+		readMode  string
+		timeField string
+		expected  string
+	}{
+		{version: "7.17.1", readMode: ReadModeAuto, timeField: "@timestamp", expected: ReadModePIT},
+		{version: "7.17.1", readMode: ReadModeAuto, expected: ReadModePaginate},
+		{version: "7.9.3", readMode: ReadModeAuto, timeField: "@timestamp", expected: ReadModeScroll},
+		{version: "6.8.23", readMode: ReadModeAuto, timeField: "@timestamp", expected: ReadModeScroll},
+		{version: "7.17.1", readMode: ReadModeScroll, timeField: "@timestamp", expected: ReadModeScroll},
+	} {
+		t.Run("test read mode "+c.version, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Elastic-Product", "Elasticsearch")
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"version": {"number": "%s", "build_flavor": "default"}, "tagline": "You Know, for Search"}`, c.version)
+			}))
+			defer srv.Close()
+
+			r, err := newReadClient(readClientConfig{address: srv.URL, readMode: c.readMode})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mode, err := r.ReadMode(context.Background(), c.timeField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if mode != c.expected {
+				t.Errorf("expecting read mode '%s', got '%s'", c.expected, mode)
+			}
+		})
+	}
+
+	if _, err := newReadClient(readClientConfig{address: "http://localhost:9200", readMode: "unknown"}); err != ErrUnknownReadMode {
+		t.Errorf("expecting error %v, got %v", ErrUnknownReadMode, err)
+	}
+}
+
+func TestFetchOptions(t *testing.T) {
+	body := map[string]any{}
+	readAllRequest{}.fetchOptions(body)
+	if _, ok := body["_source"]; ok {
+		t.Errorf("expecting no '_source' parameter, got %v", body["_source"])
+	}
+
+	readAllRequest{sourceExcludes: []string{"payload", "*.embedding"}, seqNoPrimaryTerm: true}.fetchOptions(body)
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"_source":{"excludes":["payload","*.embedding"]},"seq_no_primary_term":true,"version":true}`
+	if string(b) != expected {
+		t.Errorf("expecting body %s, got %s", expected, b)
+	}
+}
+
+func TestWriteDocumentMetadata(t *testing.T) {
+	var actions []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/_bulk" {
+			w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+			return
+		}
+
+		b, _ := io.ReadAll(r.Body)
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		for i := 0; i < len(lines); i += 2 {
+			actions = append(actions, lines[i])
+		}
+
+		w.Write([]byte(`{"errors": true, "items": [
+			{"index": {"_index": "logs", "_id": "1", "status": 201, "result": "created"}},
+			{"index": {"_index": "logs", "_id": "2", "status": 409, "error": {"type": "version_conflict_engine_exception", "reason": "version conflict"}}}
+		]}`))
+	}))
+	defer srv.Close()
+
+	cl, err := newReadWriteClient(readWriteClientConfig{host: srv.URL, externalVersion: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version := int64(7)
+	var written, failed []string
+	var results []WriteResult
+	for _, doc := range []util.Document{
+		{DocumentMetadata: util.DocumentMetadata{Index: "logs", ID: "1", Routing: "user-1", Version: &version}, Source: json.RawMessage(`{}`)},
+		{DocumentMetadata: util.DocumentMetadata{Index: "logs", ID: "2", Version: &version}, Source: json.RawMessage(`{}`)},
+	} {
+		if err := cl.WriteDocument(
+			context.Background(),
+			doc,
+			"",
+			func(doc util.DocumentMetadata, result WriteResult) {
+				written = append(written, doc.ID)
+				results = append(results, result)
+			},
+			func(doc util.DocumentMetadata, err error) { failed = append(failed, doc.ID) },
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cl.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	cl.Wait()
+	expected := []string{
+		`{"index":{"_id":"1","version":7,"version_type":"external","routing":"user-1","_index":"logs"}}`,
+		`{"index":{"_id":"2","version":7,"version_type":"external","_index":"logs"}}`,
+	}
+
+	if strings.Join(actions, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expecting bulk actions\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(actions, "\n"))
+	}
+
+	if len(written) != 2 || len(failed) != 0 || results[0] != WriteCreated || results[1] != WriteSkipped {
+		t.Errorf("expecting version conflict to be skipped, got written %v %v and failed %v", written, results, failed)
+	}
+}
+
+func TestWriteDocumentConflictPolicy(t *testing.T) {
+	for _, c := range []struct {
+		policy   string
+		action   string
+		body     string
+		result   string
+		expected WriteResult
+	}{
+		{
+			policy:   OnConflictCreate,
+			action:   `{"create":{"_id":"1","_index":"logs"}}`,
+			body:     `{"@timestamp":"2024-05-01T10:00:00Z","message":"foo"}`,
+			result:   `{"create": {"_index": "logs", "_id": "1", "status": 409, "error": {"type": "version_conflict_engine_exception", "reason": "document already exists"}}}`,
+			expected: WriteSkipped,
+		},
+		{
+			policy:   OnConflictOverwrite,
+			action:   `{"index":{"_id":"1","_index":"logs"}}`,
+			body:     `{"@timestamp":"2024-05-01T10:00:00Z","message":"foo"}`,
+			result:   `{"index": {"_index": "logs", "_id": "1", "status": 200, "result": "updated"}}`,
+			expected: WriteUpdated,
+		},
+		{
+			policy:   OnConflictMerge,
+			action:   `{"update":{"_id":"1","_index":"logs"}}`,
+			body:     `{"doc":{"@timestamp":"2024-05-01T10:00:00Z","message":"foo"},"doc_as_upsert":true}`,
+			result:   `{"update": {"_index": "logs", "_id": "1", "status": 201, "result": "created"}}`,
+			expected: WriteCreated,
+		},
+		{
+			policy:   OnConflictNewerWins,
+			action:   `{"update":{"_id":"1","_index":"logs"}}`,
+			body:     `1714557600000`,
+			result:   `{"update": {"_index": "logs", "_id": "1", "status": 200, "result": "noop"}}`,
+			expected: WriteSkipped,
+		},
+	} {
+		t.Run(c.policy, func(t *testing.T) {
+			var action, body string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Elastic-Product", "Elasticsearch")
+				w.Header().Set("Content-Type", "application/json")
+				if r.URL.Path != "/_bulk" {
+					w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+					return
+				}
+
+				b, _ := io.ReadAll(r.Body)
+				lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+				action, body = lines[0], lines[1]
+				w.Write([]byte(`{"errors": true, "items": [` + c.result + `]}`))
+			}))
+			defer srv.Close()
+
+			cl, err := newReadWriteClient(readWriteClientConfig{host: srv.URL, onConflict: c.policy})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var result WriteResult
+			doc := util.Document{
+				DocumentMetadata: util.DocumentMetadata{Index: "logs", ID: "1"},
+				Source:           json.RawMessage(`{"@timestamp": "2024-05-01T10:00:00Z", "message": "foo"}`),
+			}
+
+			if err := cl.WriteDocument(
+				context.Background(),
+				doc,
+				"@timestamp",
+				func(doc util.DocumentMetadata, r WriteResult) { result = r },
+				func(doc util.DocumentMetadata, err error) { t.Errorf("expecting no error, got %s", err.Error()) },
+			); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := cl.Flush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			cl.Wait()
+			if action != c.action {
+				t.Errorf("expecting action %s, got %s", c.action, action)
+			}
+
+			if !strings.Contains(body, c.body) {
+				t.Errorf("expecting body containing %s, got %s", c.body, body)
+			}
+
+			if result != c.expected {
+				t.Errorf("expecting result '%s', got '%s'", c.expected, result)
+			}
+		})
+	}
+
+	if _, err := newReadWriteClient(readWriteClientConfig{host: "http://localhost:9200", onConflict: "ignore"}); err != ErrUnknownConflictPolicy {
+		t.Errorf("expecting error %v, got %v", ErrUnknownConflictPolicy, err)
+	}
+
+	if _, err := newReadWriteClient(readWriteClientConfig{host: "http://localhost:9200", onConflict: OnConflictMerge, externalVersion: true}); err != ErrExternalVersionPolicy {
+		t.Errorf("expecting error %v, got %v", ErrExternalVersionPolicy, err)
+	}
+}
+
+func TestNewerWinsTimeFormats(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		value    any
+		expected int64
+		parser   string
+	}{
+		{name: "epoch millis", value: float64(1714557600000), expected: 1714557600000, parser: "Long.parseLong(v)"},
+		{name: "zoned", value: "2024-05-01T12:00:00+02:00", expected: 1714557600000, parser: "ZonedDateTime.parse(v)"},
+		{name: "zone-less", value: "2024-05-01T10:00:00", expected: 1714557600000, parser: "LocalDateTime.parse(v).toInstant(ZoneOffset.UTC)"},
+		{name: "zone-less fraction", value: "2024-05-01T10:00:00.250", expected: 1714557600250, parser: "LocalDateTime.parse(v).toInstant(ZoneOffset.UTC)"},
+		{name: "date", value: "2024-05-01", expected: 1714521600000, parser: "LocalDate.parse(v).atStartOfDay(ZoneOffset.UTC)"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			// source times are parsed the same way as destination times in the script.
+			if millis, ok := timeMillis(c.value); !ok || millis != c.expected {
+				t.Errorf("expecting %d, got %d, %t", c.expected, millis, ok)
+			}
+
+			if !strings.Contains(newerWinsScript, c.parser) {
+				t.Errorf("expecting destination times parsed with %s", c.parser)
+			}
+		})
+	}
+}
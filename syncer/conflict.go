@@ -0,0 +1,200 @@
+package syncer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+// Conflict policies, deciding what happens to documents which already exist on the destination.
+const (
+	// OnConflictCreate keeps existing destination documents, and skips the source documents.
+	OnConflictCreate = "create"
+	// OnConflictOverwrite overwrites existing destination documents.
+	OnConflictOverwrite = "overwrite"
+	// OnConflictNewerWins overwrites existing destination documents with a time field older than
+	// the source document, or with an older version if the index has no time field.
+	OnConflictNewerWins = "newer-wins"
+	// OnConflictMerge merges source documents into existing destination documents, so fields only
+	// on the destination are kept.
+	OnConflictMerge = "merge"
+)
+
+var (
+	ErrUnknownConflictPolicy = errors.New("unknown conflict policy, expecting one of 'create', 'overwrite', 'newer-wins' or 'merge'")
+	ErrExternalVersionPolicy = errors.New("external version can only be used with 'overwrite' or 'newer-wins' conflict policy")
+)
+
+// WriteResult is the outcome of writing a document.
+type WriteResult string
+
+const (
+	WriteCreated WriteResult = "created"
+	WriteUpdated WriteResult = "updated"
+	// WriteSkipped is a document not written since the destination document is kept according to
+	// the conflict policy.
+	WriteSkipped WriteResult = "skipped"
+)
+
+// newerWinsScript replaces the destination document if its time field isn't newer than the source
+// time, destination time values are either epoch milliseconds or ISO 8601 dates, dates without a
+// zone are UTC as elasticsearch does. Documents without a time value are replaced.
+const newerWinsScript = `
+def v = ctx._source;
+for (name in params.path) {
+	if (!(v instanceof Map)) { v = null; break; }
+	v = v[name];
+}
+long dest = -1;
+if (v instanceof Number) {
+	dest = ((Number) v).longValue();
+} else if (v instanceof String) {
+	try {
+		dest = ZonedDateTime.parse(v).toInstant().toEpochMilli();
+	} catch (Exception e) {
+		try {
+			dest = LocalDateTime.parse(v).toInstant(ZoneOffset.UTC).toEpochMilli();
+		} catch (Exception e2) {
+			try {
+				dest = LocalDate.parse(v).atStartOfDay(ZoneOffset.UTC).toInstant().toEpochMilli();
+			} catch (Exception e3) {
+				try { dest = Long.parseLong(v); } catch (Exception e4) {}
+			}
+		}
+	}
+}
+if (dest > params.time) {
+	ctx.op = 'noop';
+} else {
+	ctx._source = params.doc;
+}`
+
+func validConflictPolicy(policy string) bool {
+	switch policy {
+	case OnConflictCreate, OnConflictOverwrite, OnConflictNewerWins, OnConflictMerge:
+		return true
+	}
+
+	return false
+}
+
+// bulkItem returns the bulk item writing the document according to the conflict policy, the time
+// field is compared by the newer-wins policy.
+func (c *readWriteClient) bulkItem(doc util.Document, timeField string) (esutil.BulkIndexerItem, error) {
+	item := esutil.BulkIndexerItem{
+		Action:     "index",
+		DocumentID: doc.ID,
+		Index:      doc.Index,
+		Routing:    doc.Routing,
+	}
+
+	var body any = doc.Source
+	switch c.onConflict {
+	case OnConflictCreate:
+		item.Action = "create"
+	case OnConflictMerge:
+		item.Action = "update"
+		body = map[string]any{
+			"doc":           doc.Source,
+			"doc_as_upsert": true,
+		}
+	case OnConflictNewerWins:
+		t, ok := sourceTime(doc, timeField)
+		if !ok {
+			// without time field, versions are compared.
+			if doc.Version != nil {
+				item.Version = doc.Version
+				item.VersionType = versionTypeExternal
+			}
+
+			break
+		}
+
+		item.Action = "update"
+		body = map[string]any{
+			"scripted_upsert": true,
+			"upsert":          map[string]any{},
+			"script": map[string]any{
+				"lang":   "painless",
+				"source": newerWinsScript,
+				"params": map[string]any{
+					"doc":  doc.Source,
+					"path": strings.Split(timeField, "."),
+					"time": t,
+				},
+			},
+		}
+	default:
+		if c.externalVersion && doc.Version != nil {
+			item.Version = doc.Version
+			item.VersionType = versionTypeExternal
+		}
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return item, err
+	}
+
+	item.Body = bytes.NewReader(b)
+	return item, nil
+}
+
+// writeResult returns the outcome of a successful bulk item.
+func writeResult(res esutil.BulkIndexerResponseItem) WriteResult {
+	switch res.Result {
+	case "created":
+		return WriteCreated
+	case "noop":
+		return WriteSkipped
+	}
+
+	return WriteUpdated
+}
+
+// sourceTime returns the time field value of the document in epoch milliseconds.
+func sourceTime(doc util.Document, timeField string) (int64, bool) {
+	if timeField == "" {
+		return 0, false
+	}
+
+	source, err := decodeSource(doc.Source)
+	if err != nil {
+		return 0, false
+	}
+
+	value, ok := getField(source, timeField)
+	if !ok {
+		return 0, false
+	}
+
+	return timeMillis(value)
+}
+
+// timeMillis converts a date field value, either epoch milliseconds or an ISO 8601 date, to epoch
+// milliseconds.
+func timeMillis(value any) (int64, bool) {
+	str, err := fieldString(value)
+	if err != nil {
+		return 0, false
+	}
+
+	if n, err := strconv.ParseInt(str, 10, 64); err == nil {
+		return n, true
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"} {
+		if t, err := time.Parse(layout, str); err == nil {
+			return t.UnixMilli(), true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,222 @@
+package syncer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+// DefaultDeadLetterFile is the file where documents failed to be written are kept.
+const DefaultDeadLetterFile = "elastic-syncer-failed.ndjson"
+
+// DeadLetter is a document failed to be written, with the error of the last attempt. TimeField is
+// the time field compared by the newer-wins policy when the document is replayed.
+type DeadLetter struct {
+	Document    util.Document `json:"document"`
+	TimeField   string        `json:"time_field,omitempty"`
+	Status      int           `json:"status,omitempty"`
+	ErrorType   string        `json:"error_type,omitempty"`
+	ErrorReason string        `json:"error_reason"`
+	Attempts    int           `json:"attempts"`
+	FailedAt    time.Time     `json:"failed_at"`
+}
+
+func newDeadLetter(doc util.Document, timeField string, err error, attempts int) DeadLetter {
+	doc.SortMetadata = util.SortMetadata{}
+	letter := DeadLetter{
+		Document:    doc,
+		TimeField:   timeField,
+		ErrorReason: err.Error(),
+		Attempts:    attempts,
+		FailedAt:    time.Now().UTC(),
+	}
+
+	var resErr util.CommonErrorResponse
+	if errors.As(err, &resErr) {
+		letter.Status = resErr.Status
+		letter.ErrorType = resErr.Err.Type
+		letter.ErrorReason = resErr.Err.Reason
+	}
+
+	return letter
+}
+
+// deadLetterWriter appends dead letters to an NDJSON file, which is only created once a document
+// fails. Lines are written unbuffered, so documents failing after the file is closed are kept as
+// well. It's safe for concurrent use.
+type deadLetterWriter struct {
+	path string
+
+	mu    sync.Mutex
+	f     *os.File
+	count int64
+}
+
+func newDeadLetterWriter(path string) *deadLetterWriter {
+	return &deadLetterWriter{path: path}
+}
+
+func (d *deadLetterWriter) write(letter DeadLetter) error {
+	b, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.f == nil {
+		f, err := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+
+		d.f = f
+	}
+
+	if _, err := d.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+
+	d.count++
+	return nil
+}
+
+// close closes the file, and returns the number of written dead letters.
+func (d *deadLetterWriter) close() (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.f == nil {
+		return d.count, nil
+	}
+
+	err := d.f.Close()
+	d.f = nil
+	return d.count, err
+}
+
+type ReplayConfig struct {
+	// File is the dead-letter file, documents failing again are kept in it, and it's removed once
+	// every document is written.
+	File string
+
+	// OnConflict and ExternalVersion decide what happens to documents already existing on the
+	// destination, see Config.
+	OnConflict      string
+	ExternalVersion bool
+
+	ToHost         string
+	ToUsername     string
+	ToPassword     string
+	LogToRequests  bool
+	LogToResponses bool
+
+	// Logger writes the logs, defaults to text logs of at least info level on stderr.
+	Logger Logger
+}
+
+// Replayer writes the documents of a dead-letter file again.
+type Replayer struct {
+	client *readWriteClient
+	file   string
+	logger Logger
+}
+
+func NewReplayer(cfg ReplayConfig) (*Replayer, error) {
+	cfg.Logger = loggerOrDefault(cfg.Logger)
+	client, err := newReadWriteClient(readWriteClientConfig{
+		host:         cfg.ToHost,
+		username:     cfg.ToUsername,
+		password:     cfg.ToPassword,
+		logRequests:  cfg.LogToRequests,
+		logResponses: cfg.LogToResponses,
+
+		onConflict:      cfg.OnConflict,
+		externalVersion: cfg.ExternalVersion,
+
+		logger: cfg.Logger.With("cluster", "destination"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create to client, %s", err.Error())
+	}
+
+	return &Replayer{client: client, file: cfg.File, logger: cfg.Logger}, nil
+}
+
+// ReplayResult is the number of documents written, and failed again.
+type ReplayResult struct {
+	Written int64
+	Failed  int64
+}
+
+// Replay writes every document of the dead-letter file. Documents failing again are written to a
+// new dead-letter file with their attempt count incremented, which replaces the replayed file.
+func (r *Replayer) Replay(ctx context.Context) (ReplayResult, error) {
+	var result ReplayResult
+	f, err := os.Open(r.file)
+	if err != nil {
+		return result, err
+	}
+
+	defer f.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(r.file), filepath.Base(r.file)+".*")
+	if err != nil {
+		return result, err
+	}
+
+	defer os.Remove(tmp.Name())
+	tmp.Close()
+	failed := newDeadLetterWriter(tmp.Name())
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var letter DeadLetter
+		if err := dec.Decode(&letter); err != nil {
+			return result, fmt.Errorf("can not read dead-letter file, %s", err.Error())
+		}
+
+		if err := r.client.WriteDocument(
+			ctx,
+			letter.Document,
+			letter.TimeField,
+			func(doc util.DocumentMetadata, res WriteResult) {
+				atomic.AddInt64(&result.Written, 1)
+				r.logger.Debugf("done replaying document '%s/%s', %s", doc.Index, doc.ID, res)
+			},
+			func(doc util.DocumentMetadata, err error) {
+				atomic.AddInt64(&result.Failed, 1)
+				logger := r.logger.With("index", doc.Index)
+				logger.Errorf("failed to replay document '%s', %s", doc.ID, err.Error())
+				if err := failed.write(newDeadLetter(letter.Document, letter.TimeField, err, letter.Attempts+1)); err != nil {
+					logger.Errorf("failed to write document '%s' to dead-letter file, %s", doc.ID, err.Error())
+				}
+			},
+		); err != nil {
+			return result, fmt.Errorf("can not replay document '%s/%s', %s", letter.Document.Index, letter.Document.ID, err.Error())
+		}
+	}
+
+	if err := r.client.Flush(ctx); err != nil {
+		return result, fmt.Errorf("can not flush, %s", err.Error())
+	}
+
+	r.client.Wait()
+	if _, err := failed.close(); err != nil {
+		return result, err
+	}
+
+	f.Close()
+	if result.Failed == 0 {
+		return result, os.Remove(r.file)
+	}
+
+	return result, os.Rename(tmp.Name(), r.file)
+}
@@ -0,0 +1,153 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+func readDeadLetters(t *testing.T, path string) []DeadLetter {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var letters []DeadLetter
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for dec.More() {
+		var letter DeadLetter
+		if err := dec.Decode(&letter); err != nil {
+			t.Fatal(err)
+		}
+
+		letters = append(letters, letter)
+	}
+
+	return letters
+}
+
+func TestOnWriteError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.ndjson")
+	cl := &Client{deadLetters: newDeadLetterWriter(path), logger: defaultLogger()}
+
+	doc := util.Document{DocumentMetadata: util.DocumentMetadata{Index: "logs", ID: "1", Routing: "user-1"}, Source: json.RawMessage(`{"status":"foo"}`)}
+	cl.onWriteError(doc, "@timestamp", util.CommonErrorResponse{
+		Status: 400,
+		Err:    util.CommonError{Type: "mapper_parsing_exception", Reason: "failed to parse field [status] of type [long]"},
+	})
+	cl.onWriteError(doc, "", errors.New("connection refused"))
+
+	if count, err := cl.deadLetters.close(); err != nil || count != 2 {
+		t.Fatalf("expecting 2 dead letters, got %d, %v", count, err)
+	}
+
+	letters := readDeadLetters(t, path)
+	if len(letters) != 2 {
+		t.Fatalf("expecting 2 dead letters, got %+v", letters)
+	}
+
+	first := letters[0]
+	if first.Document.ID != "1" || first.Document.Routing != "user-1" || string(first.Document.Source) != `{"status":"foo"}` || first.TimeField != "@timestamp" {
+		t.Errorf("expecting the full document, got %+v", first)
+	}
+
+	if first.Status != 400 || first.ErrorType != "mapper_parsing_exception" || first.ErrorReason != "failed to parse field [status] of type [long]" || first.Attempts != 1 {
+		t.Errorf("expecting bulk error type and reason, got %+v", first)
+	}
+
+	if second := letters[1]; second.ErrorType != "" || second.ErrorReason != "connection refused" {
+		t.Errorf("expecting error reason, got %+v", second)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	failing := map[string]bool{"2": true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/_bulk" {
+			w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+			return
+		}
+
+		b, _ := io.ReadAll(r.Body)
+		var items []string
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		for i := 0; i < len(lines); i += 2 {
+			var action struct {
+				Index struct {
+					ID string `json:"_id"`
+				} `json:"index"`
+			}
+			if err := json.Unmarshal([]byte(lines[i]), &action); err != nil {
+				t.Error(err)
+			}
+
+			if failing[action.Index.ID] {
+				items = append(items, fmt.Sprintf(`{"index": {"_index": "logs", "_id": "%s", "status": 400, "error": {"type": "mapper_parsing_exception", "reason": "failed to parse"}}}`, action.Index.ID))
+				continue
+			}
+
+			items = append(items, fmt.Sprintf(`{"index": {"_index": "logs", "_id": "%s", "status": 201, "result": "created"}}`, action.Index.ID))
+		}
+
+		fmt.Fprintf(w, `{"errors": true, "items": [%s]}`, strings.Join(items, ","))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "failed.ndjson")
+	letters := newDeadLetterWriter(path)
+	for _, id := range []string{"1", "2"} {
+		doc := util.Document{DocumentMetadata: util.DocumentMetadata{Index: "logs", ID: id}, Source: json.RawMessage(`{}`)}
+		if err := letters.write(newDeadLetter(doc, "", errors.New("mapping conflict"), 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := letters.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replay := func() ReplayResult {
+		r, err := NewReplayer(ReplayConfig{File: path, ToHost: srv.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := r.Replay(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return result
+	}
+
+	if result := replay(); result.Written != 1 || result.Failed != 1 {
+		t.Errorf("expecting 1 document written and 1 failed, got %+v", result)
+	}
+
+	kept := readDeadLetters(t, path)
+	if len(kept) != 1 || kept[0].Document.ID != "2" || kept[0].Attempts != 2 || kept[0].ErrorType != "mapper_parsing_exception" {
+		t.Fatalf("expecting document '2' kept after 2 attempts, got %+v", kept)
+	}
+
+	failing["2"] = false
+	if result := replay(); result.Written != 1 || result.Failed != 0 {
+		t.Errorf("expecting 1 document written, got %+v", result)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expecting dead-letter file removed, got %v", err)
+	}
+}
@@ -0,0 +1,402 @@
+package syncer
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+const (
+	// ExportManifestFile is the manifest of an export directory.
+	ExportManifestFile = "manifest.json"
+
+	// exportSettingFile is the index settings, mappings and aliases file of an index directory.
+	exportSettingFile = "index.json"
+)
+
+type ExportConfig struct {
+	Since     time.Duration
+	Limit     int
+	Index     string
+	TimeField string
+
+	Slices   int
+	PageSize int
+	ReadMode string
+
+	// Query, QueryString, SourceIncludes and SourceExcludes select the exported documents and
+	// fields, as they select the synced documents, see Config.
+	Query          json.RawMessage
+	QueryString    string
+	SourceIncludes []string
+	SourceExcludes []string
+
+	// Dir is the directory where every index is exported to its own directory. Documents are written
+	// as NDJSON, gzipped if Gzip is set, to a new file once the current file exceeds MaxFileSize
+	// bytes. Set MaxFileSize to 0 to write every document of an index to one file.
+	Dir         string
+	Gzip        bool
+	MaxFileSize int64
+
+	FromHost         string
+	FromUsername     string
+	FromPassword     string
+	LogFromRequests  bool
+	LogFromResponses bool
+
+	// Logger writes the logs, defaults to text logs of at least info level on stderr.
+	Logger Logger
+}
+
+// ExportManifest lists the exported indices with their files, so truncated or corrupted transfers
+// are detected before importing.
+type ExportManifest struct {
+	CreatedAt time.Time       `json:"created_at"`
+	From      time.Time       `json:"from"`
+	To        time.Time       `json:"to"`
+	Indices   []IndexManifest `json:"indices"`
+}
+
+type IndexManifest struct {
+	Index     string         `json:"index"`
+	Documents int64          `json:"documents"`
+	Setting   FileManifest   `json:"setting"`
+	Files     []FileManifest `json:"files"`
+}
+
+// FileManifest is an exported file, Name is relative to the export directory.
+type FileManifest struct {
+	Name      string `json:"name"`
+	Documents int64  `json:"documents,omitempty"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+}
+
+// exportedDocument is a line of an exported documents file.
+type exportedDocument struct {
+	util.DocumentMetadata
+	Source json.RawMessage `json:"_source"`
+}
+
+// Exporter writes indices and their documents to files.
+type Exporter struct {
+	client *readClient
+	index  string
+
+	from      time.Time
+	to        time.Time
+	limit     int
+	timeField string
+
+	slices   int
+	pageSize int
+
+	filter         json.RawMessage
+	queryString    string
+	sourceIncludes []string
+	sourceExcludes []string
+
+	dir         string
+	gzip        bool
+	maxFileSize int64
+
+	logger Logger
+}
+
+func NewExporter(cfg ExportConfig) (*Exporter, error) {
+	cfg.Logger = loggerOrDefault(cfg.Logger)
+	client, err := newReadClient(readClientConfig{
+		address:      cfg.FromHost,
+		username:     cfg.FromUsername,
+		password:     cfg.FromPassword,
+		logRequests:  cfg.LogFromRequests,
+		logResponses: cfg.LogFromResponses,
+		readMode:     cfg.ReadMode,
+		logger:       cfg.Logger.With("cluster", "source"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create from client, %s", err.Error())
+	}
+
+	filter, err := parseQuery(cfg.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	return &Exporter{
+		client:    client,
+		index:     cfg.Index,
+		from:      now.Add(-cfg.Since),
+		to:        now,
+		limit:     cfg.Limit,
+		timeField: cfg.TimeField,
+
+		slices:   cfg.Slices,
+		pageSize: cfg.PageSize,
+
+		filter:         filter,
+		queryString:    cfg.QueryString,
+		sourceIncludes: cfg.SourceIncludes,
+		sourceExcludes: cfg.SourceExcludes,
+
+		dir:         cfg.Dir,
+		gzip:        cfg.Gzip,
+		maxFileSize: cfg.MaxFileSize,
+
+		logger: cfg.Logger,
+	}, nil
+}
+
+// Export writes the settings, mappings and aliases of every index, and its documents, to the index
+// directory, then writes the manifest. The manifest is written last, so an export without manifest
+// is incomplete.
+func (e *Exporter) Export(ctx context.Context) (ExportManifest, error) {
+	manifest := ExportManifest{CreatedAt: time.Now().UTC(), From: e.from, To: e.to}
+
+	e.logger.Infof("reading index settings for '%s'", e.index)
+	settings, err := e.client.ReadIndexSettings(ctx, e.index)
+	if err != nil {
+		return manifest, fmt.Errorf("can not get index settings for '%s', %s", e.index, err.Error())
+	}
+
+	if len(e.filter) > 0 || e.queryString != "" {
+		e.logger.Infof("validating query on '%s'", e.index)
+		req := readAllRequest{index: e.index, filter: e.filter, queryString: e.queryString}
+		if err := e.client.ValidateQuery(ctx, req); err != nil {
+			return manifest, fmt.Errorf("can not validate query, %s", err.Error())
+		}
+	}
+
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Index < settings[j].Index })
+	for _, setting := range settings {
+		index, err := e.exportIndex(ctx, setting)
+		if err != nil {
+			return manifest, fmt.Errorf("can not export index '%s', %s", setting.Index, err.Error())
+		}
+
+		manifest.Indices = append(manifest.Indices, index)
+	}
+
+	f, err := os.Create(filepath.Join(e.dir, ExportManifestFile))
+	if err != nil {
+		return manifest, err
+	}
+
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return manifest, err
+	}
+
+	return manifest, f.Close()
+}
+
+func (e *Exporter) exportIndex(ctx context.Context, setting util.IndexSetting) (IndexManifest, error) {
+	manifest := IndexManifest{Index: setting.Index}
+	if err := os.MkdirAll(filepath.Join(e.dir, setting.Index), 0755); err != nil {
+		return manifest, err
+	}
+
+	b, err := json.MarshalIndent(setting.Setting, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+
+	manifest.Setting, err = writeExportFile(e.dir, filepath.Join(setting.Index, exportSettingFile), b)
+	if err != nil {
+		return manifest, err
+	}
+
+	w := newDocumentWriter(e.dir, setting.Index, e.gzip, e.maxFileSize)
+	req := readAllRequest{
+		from:           e.from,
+		to:             e.to,
+		limit:          e.limit,
+		index:          setting.Index,
+		timeField:      e.timeField,
+		filter:         e.filter,
+		queryString:    e.queryString,
+		size:           e.pageSize,
+		slices:         e.slices,
+		sourceIncludes: e.sourceIncludes,
+		sourceExcludes: e.sourceExcludes,
+	}
+
+	logger := e.logger.With("index", setting.Index)
+	logger.Infof("exporting index to '%s'", filepath.Join(e.dir, setting.Index))
+	err = e.client.ReadIndex(ctx, req, setting, func(doc util.Document) {
+		w.write(exportedDocument{DocumentMetadata: doc.DocumentMetadata, Source: doc.Source})
+	})
+
+	// documents are written asynchronously, wait for them before closing the file.
+	e.client.Wait()
+	if closeErr := w.close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return manifest, err
+	}
+
+	manifest.Files = w.files
+	for _, f := range w.files {
+		manifest.Documents += f.Documents
+	}
+
+	logger.Infof("exported %d documents in %d files", manifest.Documents, len(manifest.Files))
+	return manifest, nil
+}
+
+// writeExportFile writes the file in the export directory and returns its manifest.
+func writeExportFile(dir, name string, b []byte) (FileManifest, error) {
+	if err := os.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+		return FileManifest{}, err
+	}
+
+	sum := sha256.Sum256(b)
+	return FileManifest{Name: filepath.ToSlash(name), Size: int64(len(b)), SHA256: hex.EncodeToString(sum[:])}, nil
+}
+
+// documentWriter writes documents of an index to NDJSON files, starting a new file once the current
+// file exceeds the max size. It's safe for concurrent use, the first error is kept and returned on
+// close.
+type documentWriter struct {
+	dir     string
+	index   string
+	gzip    bool
+	maxSize int64
+
+	mu    sync.Mutex
+	err   error
+	files []FileManifest
+
+	f    *os.File
+	gz   *gzip.Writer
+	w    io.Writer
+	hash hash.Hash
+	size *countingWriter
+	docs int64
+}
+
+func newDocumentWriter(dir, index string, gzip bool, maxSize int64) *documentWriter {
+	return &documentWriter{dir: dir, index: index, gzip: gzip, maxSize: maxSize}
+}
+
+func (w *documentWriter) write(doc exportedDocument) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return
+	}
+
+	w.err = w.writeLocked(doc)
+}
+
+func (w *documentWriter) writeLocked(doc exportedDocument) error {
+	if w.f != nil && w.maxSize > 0 && w.size.n >= w.maxSize {
+		if err := w.finish(); err != nil {
+			return err
+		}
+	}
+
+	if w.f == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+
+	w.docs++
+	return nil
+}
+
+// open creates the next documents file, written through the hash and size counter.
+func (w *documentWriter) open() error {
+	name := fmt.Sprintf("documents-%05d.ndjson", len(w.files)+1)
+	if w.gzip {
+		name += ".gz"
+	}
+
+	f, err := os.Create(filepath.Join(w.dir, w.index, name))
+	if err != nil {
+		return err
+	}
+
+	w.f, w.hash, w.size, w.docs = f, sha256.New(), &countingWriter{}, 0
+	w.w = io.MultiWriter(f, w.hash, w.size)
+	if w.gzip {
+		w.gz = gzip.NewWriter(w.w)
+		w.w = w.gz
+	}
+
+	return nil
+}
+
+// finish closes the current documents file and adds it to the files.
+func (w *documentWriter) finish() error {
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			return err
+		}
+	}
+
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+
+	name, _ := filepath.Rel(w.dir, w.f.Name())
+	w.files = append(w.files, FileManifest{
+		Name:      filepath.ToSlash(name),
+		Documents: w.docs,
+		Size:      w.size.n,
+		SHA256:    hex.EncodeToString(w.hash.Sum(nil)),
+	})
+
+	w.f, w.gz, w.w = nil, nil, nil
+	return nil
+}
+
+func (w *documentWriter) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return w.err
+	}
+
+	if err := w.finish(); w.err == nil {
+		w.err = err
+	}
+
+	return w.err
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	c.n += int64(len(b))
+	return len(b), nil
+}
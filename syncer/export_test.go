@@ -0,0 +1,170 @@
+package syncer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exportServer serves an index of documents read with pagination.
+func exportServer(t *testing.T, index string, docs []string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+		case "/" + index:
+			fmt.Fprintf(w, `{"%s": {"aliases": {"logs": {}}, "mappings": {"properties": {"message": {"type": "text"}}}, "settings": {"index": {"number_of_shards": "1", "uuid": "some-uuid"}}}}`, index)
+		case "/" + index + "/_search":
+			var body struct {
+				From int `json:"from"`
+				Size int `json:"size"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Error(err)
+			}
+
+			hits := []string{}
+			for i := body.From; i < len(docs) && i < body.From+body.Size; i++ {
+				hits = append(hits, fmt.Sprintf(`{"_index": "%s", "_id": "%d", "_version": 3, "_source": %s}`, index, i, docs[i]))
+			}
+
+			fmt.Fprintf(w, `{"hits": {"total": {"value": %d, "relation": "eq"}, "hits": [%s]}}`, len(docs), strings.Join(hits, ","))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestExport(t *testing.T) {
+	docs := []string{`{"message": "a"}`, `{"message": "b"}`, `{"message": "c"}`, `{"message": "d"}`, `{"message": "e"}`}
+	srv := exportServer(t, "logs-1", docs)
+	defer srv.Close()
+
+	for _, c := range []struct {
+		name        string
+		gzip        bool
+		maxFileSize int64
+		files       int
+	}{
+		{name: "single file", files: 1},
+		{name: "rotated", maxFileSize: 1, files: 5},
+		{name: "gzip", gzip: true, files: 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			e, err := NewExporter(ExportConfig{
+				Index:       "logs-1",
+				PageSize:    2,
+				ReadMode:    ReadModePaginate,
+				Dir:         dir,
+				Gzip:        c.gzip,
+				MaxFileSize: c.maxFileSize,
+				FromHost:    srv.URL,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := e.Export(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := os.ReadFile(filepath.Join(dir, ExportManifestFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var manifest ExportManifest
+			if err := json.Unmarshal(b, &manifest); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(manifest.Indices) != 1 || manifest.Indices[0].Index != "logs-1" || manifest.Indices[0].Documents != 5 {
+				t.Fatalf("expecting manifest of 5 documents of index 'logs-1', got %+v", manifest.Indices)
+			}
+
+			index := manifest.Indices[0]
+			if len(index.Files) != c.files {
+				t.Errorf("expecting %d files, got %+v", c.files, index.Files)
+			}
+
+			setting, err := os.ReadFile(filepath.Join(dir, index.Setting.Name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(string(setting), `"aliases"`) || !strings.Contains(string(setting), `"message"`) {
+				t.Errorf("expecting index settings, mappings and aliases, got %s", setting)
+			}
+
+			messages := map[string]bool{}
+			for _, f := range append([]FileManifest{index.Setting}, index.Files...) {
+				b, err := os.ReadFile(filepath.Join(dir, f.Name))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				sum := sha256.Sum256(b)
+				if hex.EncodeToString(sum[:]) != f.SHA256 || int64(len(b)) != f.Size {
+					t.Errorf("expecting file '%s' of %d bytes to match its checksum", f.Name, f.Size)
+				}
+
+				if f.Name == index.Setting.Name {
+					continue
+				}
+
+				var r io.Reader = strings.NewReader(string(b))
+				if c.gzip {
+					if r, err = gzip.NewReader(r); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				var count int64
+				scanner := bufio.NewScanner(r)
+				for scanner.Scan() {
+					var doc exportedDocument
+					if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+						t.Fatal(err)
+					}
+
+					if doc.Index != "logs-1" || doc.Version == nil || *doc.Version != 3 {
+						t.Errorf("expecting document metadata, got %+v", doc.DocumentMetadata)
+					}
+
+					var source struct {
+						Message string `json:"message"`
+					}
+					if err := json.Unmarshal(doc.Source, &source); err != nil {
+						t.Fatal(err)
+					}
+
+					messages[source.Message] = true
+					count++
+				}
+
+				if count != f.Documents {
+					t.Errorf("expecting %d documents in file '%s', got %d", f.Documents, f.Name, count)
+				}
+			}
+
+			if len(messages) != len(docs) {
+				t.Errorf("expecting %d exported documents, got %v", len(docs), messages)
+			}
+		})
+	}
+}
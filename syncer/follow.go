@@ -0,0 +1,110 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+const maxTimeAggregation = "max_time"
+
+// maxTime returns the latest value of the time field in the index, ok is false if no document
+// has the field.
+func maxTime(ctx context.Context, cl *elasticsearch.Client, index, timeField string) (t time.Time, ok bool, err error) {
+	b, err := json.Marshal(map[string]any{
+		"size": 0,
+		"aggs": map[string]any{
+			maxTimeAggregation: map[string]any{
+				"max": map[string]string{
+					"field": timeField,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	res, err := cl.Search(
+		cl.Search.WithContext(ctx),
+		cl.Search.WithIndex(index),
+		cl.Search.WithBody(bytes.NewReader(b)),
+	)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	agg, err := util.ParseMaxAggregation(res, maxTimeAggregation)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	t, ok = agg.Time()
+	return t, ok, nil
+}
+
+// followIndices polls every index with a time field every interval, for documents dated since the
+// last synced watermark, and writes them to the destination until the context is cancelled.
+// Indices without time field can't be followed and are ignored.
+func (c *Client) followIndices(ctx context.Context, settings []util.IndexSetting, watermarks map[string]time.Time) {
+	ticker := time.NewTicker(c.followInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, setting := range settings {
+			timeField, err := resolveTimeField(setting.Setting.Mappings, c.timeField)
+			if err != nil || timeField == "" {
+				continue
+			}
+
+			to := time.Now().UTC()
+			req := c.request(setting.Index)
+			req.from, req.to, req.limit, req.timeField = watermarks[setting.Index], to, 0, timeField
+
+			if err := c.fromClient.ReadIndex(ctx, req, setting, c.onRead(ctx, nil, timeField)); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+
+				c.logger.With("index", setting.Index).Errorf("failed to follow index, %s", err.Error())
+				continue
+			}
+
+			watermarks[setting.Index] = to
+			c.logLag(ctx, setting.Index, timeField)
+		}
+	}
+}
+
+// logLag logs the difference between the latest time field value on source and destination.
+func (c *Client) logLag(ctx context.Context, index, timeField string) {
+	source, ok, err := c.fromClient.MaxTime(ctx, index, timeField)
+	if err != nil || !ok {
+		return
+	}
+
+	logger := c.logger.With("index", index)
+	dest, ok, err := c.toClient.MaxTime(ctx, c.renames.apply(index), timeField)
+	if err != nil {
+		logger.Warnf("can not get latest '%s' of index '%s' on destination elasticsearch, %s", timeField, c.renames.apply(index), err.Error())
+		return
+	}
+
+	if !ok {
+		logger.With("lag", "unknown").Infof("source latest '%s', destination has no document", source.Format(time.RFC3339Nano))
+		return
+	}
+
+	logger.With("lag", source.Sub(dest)).Infof("source latest '%s', destination latest '%s'", source.Format(time.RFC3339Nano), dest.Format(time.RFC3339Nano))
+}
@@ -0,0 +1,467 @@
+package syncer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+// DefaultImportWorkers is the default number of files read concurrently.
+const DefaultImportWorkers = 4
+
+var (
+	ErrNoImportIndex     = errors.New("document has no index, an index must be specified")
+	ErrUnknownImportLine = errors.New("line is neither a document nor a bulk action")
+)
+
+type ImportConfig struct {
+	// Paths are either export directories, whose files are verified against the manifest and whose
+	// indices are created before loading, or NDJSON files, optionally gzipped, with documents as
+	// exported or dumped by elasticdump, or `_bulk` actions.
+	Paths []string
+
+	// Index is the index documents are loaded into instead of their own index, and Rename are rules
+	// renaming indices, see Config.Rename.
+	Index  string
+	Rename []string
+
+	// Workers is the number of files read concurrently.
+	Workers int
+
+	// TimeField, OnConflict and ExternalVersion decide what happens to documents already existing
+	// in the cluster, see Config.
+	TimeField       string
+	OnConflict      string
+	ExternalVersion bool
+
+	ToHost         string
+	ToUsername     string
+	ToPassword     string
+	LogToRequests  bool
+	LogToResponses bool
+
+	// Logger writes the logs, defaults to text logs of at least info level on stderr.
+	Logger Logger
+}
+
+// Importer loads exported indices and NDJSON dumps into a cluster.
+type Importer struct {
+	client  *readWriteClient
+	paths   []string
+	index   string
+	renames renameRules
+	workers int
+
+	timeField string
+	logger    Logger
+}
+
+func NewImporter(cfg ImportConfig) (*Importer, error) {
+	cfg.Logger = loggerOrDefault(cfg.Logger)
+	client, err := newReadWriteClient(readWriteClientConfig{
+		host:         cfg.ToHost,
+		username:     cfg.ToUsername,
+		password:     cfg.ToPassword,
+		logRequests:  cfg.LogToRequests,
+		logResponses: cfg.LogToResponses,
+
+		onConflict:      cfg.OnConflict,
+		externalVersion: cfg.ExternalVersion,
+
+		logger: cfg.Logger.With("cluster", "destination"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create to client, %s", err.Error())
+	}
+
+	renames, err := parseRenameRules(cfg.Rename)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Workers == 0 {
+		cfg.Workers = DefaultImportWorkers
+	}
+
+	return &Importer{
+		client:    client,
+		paths:     cfg.Paths,
+		index:     cfg.Index,
+		renames:   renames,
+		workers:   cfg.Workers,
+		timeField: cfg.TimeField,
+		logger:    cfg.Logger,
+	}, nil
+}
+
+// ImportResult is the number of documents loaded, and failed to be loaded.
+type ImportResult struct {
+	Documents int64
+	Failed    int64
+}
+
+// importFile is a documents file, with the time field of its index compared by the newer-wins
+// policy.
+type importFile struct {
+	path      string
+	timeField string
+}
+
+// Import verifies every export directory against its manifest and creates its indices, then loads
+// every documents file, reading Workers files concurrently.
+func (i *Importer) Import(ctx context.Context) (ImportResult, error) {
+	var files []importFile
+	for _, path := range i.paths {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return ImportResult{}, err
+		}
+
+		if !stat.IsDir() {
+			files = append(files, importFile{path: path, timeField: i.timeField})
+			continue
+		}
+
+		bundle, err := i.prepareBundle(ctx, path)
+		if err != nil {
+			return ImportResult{}, fmt.Errorf("can not import '%s', %s", path, err.Error())
+		}
+
+		files = append(files, bundle...)
+	}
+
+	var result ImportResult
+	g, gctx := errgroup.WithContext(ctx)
+	queue := make(chan importFile)
+	for n := 0; n < i.workers; n++ {
+		g.Go(func() error {
+			for f := range queue {
+				if err := i.importFile(gctx, f, &result); err != nil {
+					return fmt.Errorf("can not import '%s', %s", f.path, err.Error())
+				}
+			}
+
+			return nil
+		})
+	}
+
+	g.Go(func() error {
+		defer close(queue)
+		for _, f := range files {
+			select {
+			case queue <- f:
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+		}
+
+		return nil
+	})
+
+	err := g.Wait()
+	if flushErr := i.client.Flush(ctx); err == nil && flushErr != nil {
+		err = fmt.Errorf("can not flush, %s", flushErr.Error())
+	}
+
+	i.client.Wait()
+	return result, err
+}
+
+// prepareBundle verifies the files of the export directory against its manifest, creates its
+// indices which don't exist, and returns its documents files.
+func (i *Importer) prepareBundle(ctx context.Context, dir string) ([]importFile, error) {
+	manifest, err := LoadExportManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	i.logger.Infof("verifying %d indices exported to '%s'", len(manifest.Indices), dir)
+	if err := VerifyExport(dir, manifest); err != nil {
+		return nil, err
+	}
+
+	var files []importFile
+	for _, index := range manifest.Indices {
+		b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(index.Setting.Name)))
+		if err != nil {
+			return nil, err
+		}
+
+		setting := util.IndexSetting{Index: i.destination(index.Index)}
+		if err := json.Unmarshal(b, &setting.Setting); err != nil {
+			return nil, fmt.Errorf("can not parse settings of index '%s', %s", index.Index, err.Error())
+		}
+
+		setting.Setting.Settings = setting.Setting.Settings.Filter(nil, util.DefaultSettingsDeny)
+		exist, err := i.client.IndexExist(ctx, setting.Index)
+		if err != nil {
+			return nil, fmt.Errorf("can not check index exist for '%s', %s", setting.Index, err.Error())
+		}
+
+		logger := i.logger.With("index", setting.Index)
+		if exist {
+			logger.Infof("index exist on destination elasticsearch")
+		} else {
+			logger.Infof("index doesn't exist on destination elasticsearch, creating...")
+			if err := i.client.CreateIndex(ctx, setting); err != nil {
+				return nil, fmt.Errorf("failed to create index '%s', %s", setting.Index, err.Error())
+			}
+		}
+
+		timeField, err := resolveTimeField(setting.Setting.Mappings, i.timeField)
+		if err != nil {
+			return nil, fmt.Errorf("can not import index '%s', %s", setting.Index, err.Error())
+		}
+
+		for _, f := range index.Files {
+			files = append(files, importFile{path: filepath.Join(dir, filepath.FromSlash(f.Name)), timeField: timeField})
+		}
+	}
+
+	return files, nil
+}
+
+// destination returns the index a document of the index is loaded into.
+func (i *Importer) destination(index string) string {
+	if i.index != "" {
+		index = i.index
+	}
+
+	return i.renames.apply(index)
+}
+
+// LoadExportManifest reads the manifest of the export directory.
+func LoadExportManifest(dir string) (ExportManifest, error) {
+	var manifest ExportManifest
+	b, err := os.ReadFile(filepath.Join(dir, ExportManifestFile))
+	if err != nil {
+		return manifest, err
+	}
+
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return manifest, fmt.Errorf("can not parse manifest, %s", err.Error())
+	}
+
+	return manifest, nil
+}
+
+// VerifyExport checks the size and SHA-256 checksum of every file of the manifest.
+func VerifyExport(dir string, manifest ExportManifest) error {
+	for _, index := range manifest.Indices {
+		for _, expected := range append([]FileManifest{index.Setting}, index.Files...) {
+			f, err := os.Open(filepath.Join(dir, filepath.FromSlash(expected.Name)))
+			if err != nil {
+				return err
+			}
+
+			h := sha256.New()
+			size, err := io.Copy(h, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+
+			if size != expected.Size {
+				return fmt.Errorf("file '%s' is %d bytes instead of %d, it may be truncated", expected.Name, size, expected.Size)
+			}
+
+			if sum := hex.EncodeToString(h.Sum(nil)); sum != expected.SHA256 {
+				return fmt.Errorf("file '%s' checksum is '%s' instead of '%s'", expected.Name, sum, expected.SHA256)
+			}
+		}
+	}
+
+	return nil
+}
+
+// importFile writes every document of the file, and counts them in the result.
+func (i *Importer) importFile(ctx context.Context, f importFile, result *ImportResult) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(f.path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+
+		defer gz.Close()
+		r = gz
+	}
+
+	logger := i.logger.With("file", f.path)
+	logger.Infof("importing documents")
+	lines := newLineReader(r)
+	for {
+		action, doc, err := lines.next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("line %d, %s", lines.n, err.Error())
+		}
+
+		if doc.Index == "" && i.index == "" {
+			return fmt.Errorf("line %d, %s", lines.n, ErrNoImportIndex.Error())
+		}
+
+		doc.Index = i.destination(doc.Index)
+		onError := func(doc util.DocumentMetadata, err error) {
+			atomic.AddInt64(&result.Failed, 1)
+			logger.With("index", doc.Index).Errorf("failed to import document '%s', %s", doc.ID, err.Error())
+		}
+
+		onSuccess := func(doc util.DocumentMetadata, res WriteResult) {
+			atomic.AddInt64(&result.Documents, 1)
+		}
+
+		switch action {
+		case "delete":
+			err = i.client.DeleteDocument(ctx, doc.DocumentMetadata, func(doc util.DocumentMetadata) {
+				atomic.AddInt64(&result.Documents, 1)
+			}, onError)
+		case "create", "update":
+			// create and update actions keep their own semantics, an update only changes the
+			// fields of its partial document.
+			err = i.client.WriteAction(ctx, action, doc, onSuccess, onError)
+		default:
+			err = i.client.WriteDocument(ctx, doc, f.timeField, onSuccess, onError)
+		}
+
+		if err != nil {
+			return fmt.Errorf("can not import document '%s/%s', %s", doc.Index, doc.ID, err.Error())
+		}
+	}
+}
+
+// lineReader reads documents from NDJSON, either one document per line as exported or dumped by
+// elasticdump, or `_bulk` actions followed by the document source, except for delete actions.
+type lineReader struct {
+	r *bufio.Reader
+	n int
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{r: bufio.NewReader(r)}
+}
+
+// bulkAction is the metadata of a `_bulk` action, routing is `_routing` before elasticsearch 7.
+type bulkAction struct {
+	Index       string `json:"_index"`
+	ID          string `json:"_id"`
+	Routing     string `json:"routing"`
+	RoutingPre7 string `json:"_routing"`
+	Version     *int64 `json:"version"`
+}
+
+// next returns the next document and its action, either index, create, update or delete. The source
+// of an update action is its body, with the partial document.
+func (l *lineReader) next() (string, util.Document, error) {
+	b, err := l.line()
+	if err != nil {
+		return "", util.Document{}, err
+	}
+
+	var line map[string]json.RawMessage
+	if err := json.Unmarshal(b, &line); err != nil {
+		return "", util.Document{}, err
+	}
+
+	if _, ok := line["_source"]; ok {
+		var doc exportedDocument
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return "", util.Document{}, err
+		}
+
+		return "index", util.Document{DocumentMetadata: doc.DocumentMetadata, Source: doc.Source}, nil
+	}
+
+	if len(line) != 1 {
+		return "", util.Document{}, ErrUnknownImportLine
+	}
+
+	for action, meta := range line {
+		var a bulkAction
+		if err := json.Unmarshal(meta, &a); err != nil {
+			return "", util.Document{}, err
+		}
+
+		if a.Routing == "" {
+			a.Routing = a.RoutingPre7
+		}
+
+		doc := util.Document{DocumentMetadata: util.DocumentMetadata{Index: a.Index, ID: a.ID, Routing: a.Routing, Version: a.Version}}
+		switch action {
+		case "delete":
+			return action, doc, nil
+		case "index", "create", "update":
+		default:
+			return "", util.Document{}, ErrUnknownImportLine
+		}
+
+		source, err := l.line()
+		if err == io.EOF {
+			return "", util.Document{}, io.ErrUnexpectedEOF
+		}
+
+		if err != nil {
+			return "", util.Document{}, err
+		}
+
+		doc.Source = source
+		if action == "update" {
+			var update struct {
+				Doc json.RawMessage `json:"doc"`
+			}
+			if err := json.Unmarshal(source, &update); err != nil {
+				return "", util.Document{}, err
+			}
+
+			if len(update.Doc) == 0 {
+				return "", util.Document{}, fmt.Errorf("update action of document '%s' has no partial document, scripted updates are not supported", a.ID)
+			}
+		}
+
+		return action, doc, nil
+	}
+
+	return "", util.Document{}, ErrUnknownImportLine
+}
+
+// line returns the next non-empty line.
+func (l *lineReader) line() ([]byte, error) {
+	for {
+		b, err := l.r.ReadBytes('\n')
+		if len(b) > 0 || err == nil {
+			l.n++
+		}
+
+		if b = bytes.TrimSpace(b); len(b) > 0 {
+			return b, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
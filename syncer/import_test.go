@@ -0,0 +1,261 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLineReader(t *testing.T) {
+	input := strings.Join([]string{
+		`{"_index": "logs", "_type": "_doc", "_id": "1", "_source": {"message": "elasticdump"}}`,
+		``,
+		`{"index": {"_index": "logs", "_id": "2", "routing": "user-1"}}`,
+		`{"message": "bulk index"}`,
+		`{"create": {"_index": "logs", "_id": "3", "_routing": "user-2"}}`,
+		`{"message": "bulk create"}`,
+		`{"delete": {"_index": "logs", "_id": "4"}}`,
+		`{"update": {"_index": "logs", "_id": "5"}}`,
+		`{"doc": {"message": "bulk update"}}`,
+	}, "\n")
+
+	expected := []string{
+		`index logs/1 {"message": "elasticdump"}`,
+		`index logs/2@user-1 {"message": "bulk index"}`,
+		`create logs/3@user-2 {"message": "bulk create"}`,
+		`delete logs/4 `,
+		`update logs/5 {"doc": {"message": "bulk update"}}`,
+	}
+
+	var got []string
+	r := newLineReader(strings.NewReader(input))
+	for {
+		action, doc, err := r.next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("line %d, %v", r.n, err)
+		}
+
+		id := doc.Index + "/" + doc.ID
+		if doc.Routing != "" {
+			id += "@" + doc.Routing
+		}
+
+		got = append(got, fmt.Sprintf("%s %s %s", action, id, string(doc.Source)))
+	}
+
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expecting documents\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestLineReaderInvalid(t *testing.T) {
+	for _, input := range []string{
+		`not json`,
+		`{"foo": "bar", "baz": "qux"}`,
+		`{"search": {"_index": "logs"}}`,
+		`{"index": {"_index": "logs", "_id": "1"}}`,
+		"{\"update\": {\"_index\": \"logs\", \"_id\": \"1\"}}\n{\"script\": {\"source\": \"ctx._source.count++\"}}",
+	} {
+		if _, _, err := newLineReader(strings.NewReader(input)).next(); err == nil || err == io.EOF {
+			t.Errorf("expecting error reading '%s', got %v", input, err)
+		}
+	}
+}
+
+// exportBundle exports the documents to a temporary directory.
+func exportBundle(t *testing.T, docs []string) string {
+	srv := exportServer(t, "logs-1", docs)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	e, err := NewExporter(ExportConfig{Index: "logs-1", ReadMode: ReadModePaginate, Dir: dir, Gzip: true, FromHost: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := e.Export(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestVerifyExport(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		modify func(path string) error
+		err    string
+	}{
+		{name: "intact"},
+		{
+			name: "truncated",
+			modify: func(path string) error {
+				return os.Truncate(path, 10)
+			},
+			err: "truncated",
+		},
+		{
+			name: "corrupted",
+			modify: func(path string) error {
+				b, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+
+				b[len(b)/2] ^= 0xff
+				return os.WriteFile(path, b, 0644)
+			},
+			err: "checksum",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := exportBundle(t, []string{`{"message": "a"}`, `{"message": "b"}`})
+			manifest, err := LoadExportManifest(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.modify != nil {
+				if err := c.modify(filepath.Join(dir, manifest.Indices[0].Files[0].Name)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = VerifyExport(dir, manifest)
+			if c.err == "" && err != nil {
+				t.Errorf("expecting no error, got %v", err)
+			}
+
+			if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+				t.Errorf("expecting error containing '%s', got %v", c.err, err)
+			}
+		})
+	}
+}
+
+// bulkServer records created indices and bulk actions, every bulk action succeeds.
+func bulkServer(t *testing.T) (srv *httptest.Server, created map[string]string, actions func() []string) {
+	var mu sync.Mutex
+	var recorded []string
+	created = map[string]string{}
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/":
+			w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+		case r.URL.Path == "/_bulk":
+			b, _ := io.ReadAll(r.Body)
+			var items []string
+			lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+			for i := 0; i < len(lines); i++ {
+				var line map[string]json.RawMessage
+				if err := json.Unmarshal([]byte(lines[i]), &line); err != nil {
+					t.Error(err)
+				}
+
+				for action := range line {
+					items = append(items, fmt.Sprintf(`{"%s": {"status": 200, "result": "created"}}`, action))
+					if action != "delete" {
+						recorded = append(recorded, lines[i]+" "+lines[i+1])
+						i++
+					} else {
+						recorded = append(recorded, lines[i])
+					}
+				}
+			}
+
+			fmt.Fprintf(w, `{"errors": false, "items": [%s]}`, strings.Join(items, ","))
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPut:
+			b, _ := io.ReadAll(r.Body)
+			created[strings.TrimPrefix(r.URL.Path, "/")] = string(b)
+			fmt.Fprintf(w, `{"acknowledged": true, "shards_acknowledged": true, "index": "%s"}`, strings.TrimPrefix(r.URL.Path, "/"))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return srv, created, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		sort.Strings(recorded)
+		return append([]string{}, recorded...)
+	}
+}
+
+func TestImport(t *testing.T) {
+	bundle := exportBundle(t, []string{`{"message": "a"}`, `{"message": "b"}`})
+	dump := filepath.Join(t.TempDir(), "dump.ndjson")
+	if err := os.WriteFile(dump, []byte(strings.Join([]string{
+		`{"index": {"_index": "logs-2", "_id": "x"}}`,
+		`{"message": "c"}`,
+		`{"delete": {"_index": "logs-2", "_id": "y"}}`,
+		`{"create": {"_index": "logs-2", "_id": "z"}}`,
+		`{"message": "d"}`,
+		`{"update": {"_index": "logs-2", "_id": "x"}}`,
+		`{"doc": {"status": "done"}, "doc_as_upsert": true}`,
+	}, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, created, actions := bulkServer(t)
+	defer srv.Close()
+
+	i, err := NewImporter(ImportConfig{
+		Paths:  []string{bundle, dump},
+		Rename: []string{"prefix:logs-=copy-"},
+		ToHost: srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := i.Import(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Documents != 6 || result.Failed != 0 {
+		t.Errorf("expecting 6 documents imported, got %+v", result)
+	}
+
+	setting, ok := created["copy-1"]
+	if !ok || len(created) != 1 {
+		t.Fatalf("expecting index 'copy-1' created, got %v", created)
+	}
+
+	if strings.Contains(setting, "uuid") || !strings.Contains(setting, `"message"`) {
+		t.Errorf("expecting index created with mappings and without uuid, got %s", setting)
+	}
+
+	expected := []string{
+		`{"create":{"_id":"z","_index":"copy-2"}} {"message": "d"}`,
+		`{"delete":{"_id":"y","_index":"copy-2"}}`,
+		`{"index":{"_id":"0","_index":"copy-1"}} {"message":"a"}`,
+		`{"index":{"_id":"1","_index":"copy-1"}} {"message":"b"}`,
+		`{"index":{"_id":"x","_index":"copy-2"}} {"message":"c"}`,
+		`{"update":{"_id":"x","_index":"copy-2"}} {"doc": {"status": "done"}, "doc_as_upsert": true}`,
+	}
+
+	if got := actions(); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expecting bulk actions\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,314 @@
+package syncer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// redacted replaces credentials in logs.
+const redacted = "[REDACTED]"
+
+// redactedHeaders are the request headers carrying credentials.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+var (
+	// ErrUnknownLogFormat is error returned when creating a logger with an unknown format.
+	ErrUnknownLogFormat = errors.New("unknown log format")
+
+	// ErrUnknownLogLevel is error returned when parsing an unknown log level.
+	ErrUnknownLogLevel = errors.New("unknown log level")
+)
+
+// Level is the severity of a log entry.
+type Level int
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+
+	return strconv.Itoa(int(l))
+}
+
+// ParseLevel parses either 'debug', 'info', 'warn' or 'error'.
+func ParseLevel(s string) (Level, error) {
+	for _, l := range []Level{LevelDebug, LevelInfo, LevelWarn, LevelError} {
+		if strings.EqualFold(s, l.String()) {
+			return l, nil
+		}
+	}
+
+	return 0, ErrUnknownLogLevel
+}
+
+// Logger writes leveled log entries with fields, it's safe for concurrent use.
+type Logger interface {
+	Debugf(format string, v ...any)
+	Infof(format string, v ...any)
+	Warnf(format string, v ...any)
+	Errorf(format string, v ...any)
+
+	// With returns a logger adding the fields to every entry, keyvals are alternating keys and
+	// values.
+	With(keyvals ...any) Logger
+}
+
+// NewLogger returns a logger writing entries of at least the level to w, either as text or as JSON
+// lines.
+func NewLogger(w io.Writer, format string, level Level) (Logger, error) {
+	if format == "" {
+		format = LogFormatText
+	}
+
+	if format != LogFormatText && format != LogFormatJSON {
+		return nil, ErrUnknownLogFormat
+	}
+
+	return &logger{out: w, mu: &sync.Mutex{}, json: format == LogFormatJSON, level: level}, nil
+}
+
+// defaultLogger writes text entries of at least info level to stderr.
+func defaultLogger() Logger {
+	return &logger{out: os.Stderr, mu: &sync.Mutex{}, level: LevelInfo}
+}
+
+// loggerOrDefault returns l, or the default logger if l is nil.
+func loggerOrDefault(l Logger) Logger {
+	if l == nil {
+		return defaultLogger()
+	}
+
+	return l
+}
+
+type field struct {
+	key   string
+	value any
+}
+
+type logger struct {
+	out    io.Writer
+	mu     *sync.Mutex
+	json   bool
+	level  Level
+	fields []field
+}
+
+func (l *logger) Debugf(format string, v ...any) { l.logf(LevelDebug, format, v...) }
+func (l *logger) Infof(format string, v ...any)  { l.logf(LevelInfo, format, v...) }
+func (l *logger) Warnf(format string, v ...any)  { l.logf(LevelWarn, format, v...) }
+func (l *logger) Errorf(format string, v ...any) { l.logf(LevelError, format, v...) }
+
+func (l *logger) With(keyvals ...any) Logger {
+	fields := append([]field{}, l.fields...)
+	for i := 0; i < len(keyvals); i += 2 {
+		f := field{key: fmt.Sprint(keyvals[i])}
+		if i+1 < len(keyvals) {
+			f.value = keyvals[i+1]
+		}
+
+		fields = append(fields, f)
+	}
+
+	return &logger{out: l.out, mu: l.mu, json: l.json, level: l.level, fields: fields}
+}
+
+func (l *logger) logf(level Level, format string, v ...any) {
+	if level < l.level {
+		return
+	}
+
+	msg := strings.TrimRight(fmt.Sprintf(format, v...), "\n")
+	var b bytes.Buffer
+	if l.json {
+		l.appendJSON(&b, time.Now(), level, msg)
+	} else {
+		l.appendText(&b, time.Now(), level, msg)
+	}
+
+	b.WriteByte('\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(b.Bytes())
+}
+
+func (l *logger) appendText(b *bytes.Buffer, now time.Time, level Level, msg string) {
+	b.WriteString(now.Format("2006/01/02 15:04:05"))
+	b.WriteByte(' ')
+	b.WriteString(strings.ToUpper(level.String()))
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for _, f := range l.fields {
+		b.WriteByte(' ')
+		b.WriteString(f.key)
+		b.WriteByte('=')
+		b.WriteString(textValue(f.value))
+	}
+}
+
+// textValue formats a field value, quoting it if it contains spaces, quotes or '='.
+func textValue(v any) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+func (l *logger) appendJSON(b *bytes.Buffer, now time.Time, level Level, msg string) {
+	b.WriteString(`{"time":`)
+	appendJSONValue(b, now.UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	appendJSONValue(b, level.String())
+	b.WriteString(`,"msg":`)
+	appendJSONValue(b, msg)
+	for _, f := range l.fields {
+		b.WriteByte(',')
+		appendJSONValue(b, f.key)
+		b.WriteByte(':')
+		appendJSONValue(b, f.value)
+	}
+
+	b.WriteByte('}')
+}
+
+func appendJSONValue(b *bytes.Buffer, v any) {
+	switch value := v.(type) {
+	case error:
+		v = value.Error()
+	case time.Duration:
+		v = value.String()
+	case json.RawMessage:
+		if !json.Valid(value) {
+			v = string(value)
+		}
+	}
+
+	enc, err := json.Marshal(v)
+	if err != nil {
+		enc, _ = json.Marshal(fmt.Sprint(v))
+	}
+
+	b.Write(enc)
+}
+
+// NewLogWriter returns a writer logging every written line at the level, to route the standard
+// library logger through l.
+func NewLogWriter(l Logger, level Level) io.Writer {
+	return logWriter{logger: l, level: level}
+}
+
+type logWriter struct {
+	logger Logger
+	level  Level
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		logAt(w.logger, w.level, "%s", line)
+	}
+
+	return len(p), nil
+}
+
+func logAt(l Logger, level Level, format string, v ...any) {
+	switch level {
+	case LevelDebug:
+		l.Debugf(format, v...)
+	case LevelInfo:
+		l.Infof(format, v...)
+	case LevelWarn:
+		l.Warnf(format, v...)
+	default:
+		l.Errorf(format, v...)
+	}
+}
+
+// transportLogger logs elasticsearch requests and responses through a logger, with credentials
+// redacted from the URL and headers.
+type transportLogger struct {
+	logger       Logger
+	requestBody  bool
+	responseBody bool
+}
+
+func (t *transportLogger) LogRoundTrip(req *http.Request, res *http.Response, err error, start time.Time, dur time.Duration) error {
+	l := t.logger.With(
+		"method", req.Method,
+		"url", req.URL.Redacted(),
+		"duration", dur,
+		"request_headers", redactHeaders(req.Header),
+	)
+
+	if t.requestBody && req.Body != nil && req.Body != http.NoBody {
+		b, _ := io.ReadAll(req.Body)
+		l = l.With("request_body", string(b))
+	}
+
+	if res != nil && res.StatusCode != 0 {
+		l = l.With("status", res.StatusCode)
+		if t.responseBody && res.Body != nil && res.Body != http.NoBody {
+			b, _ := io.ReadAll(res.Body)
+			l = l.With("response_body", string(b))
+		}
+	}
+
+	if err != nil {
+		l.With("error", err).Warnf("elasticsearch request failed")
+		return nil
+	}
+
+	l.Infof("elasticsearch request")
+	return nil
+}
+
+func (t *transportLogger) RequestBodyEnabled() bool  { return t.requestBody }
+func (t *transportLogger) ResponseBodyEnabled() bool { return t.responseBody }
+
+// redactHeaders returns the headers as a sorted 'name: value' list, with credentials redacted.
+func redactHeaders(h http.Header) []string {
+	headers := make([]string, 0, len(h))
+	for name, values := range h {
+		value := strings.Join(values, ", ")
+		for _, r := range redactedHeaders {
+			if strings.EqualFold(name, r) {
+				value = redacted
+			}
+		}
+
+		headers = append(headers, name+": "+value)
+	}
+
+	sort.Strings(headers)
+	return headers
+}
@@ -0,0 +1,136 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		format   string
+		level    Level
+		log      func(l Logger)
+		expected []string
+	}{
+		{
+			name:     "text",
+			format:   LogFormatText,
+			level:    LevelInfo,
+			log:      func(l Logger) { l.With("index", "logs", "query", "a = b").Infof("reading %d documents\n", 10) },
+			expected: []string{`INFO reading 10 documents index=logs query="a = b"`},
+		},
+		{
+			name:     "level",
+			format:   LogFormatText,
+			level:    LevelWarn,
+			log:      func(l Logger) { l.Debugf("found document"); l.Infof("reading"); l.Warnf("slow"); l.Errorf("failed") },
+			expected: []string{"WARN slow", "ERROR failed"},
+		},
+		{
+			name:   "json",
+			format: LogFormatJSON,
+			level:  LevelDebug,
+			log: func(l Logger) {
+				l.With("index", "logs").With("read", 10, "error", errors.New("timeout")).Debugf("found document '%s'", "1")
+			},
+			expected: []string{`"level":"debug","msg":"found document '1'","index":"logs","read":10,"error":"timeout"}`},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+			l, err := NewLogger(&out, c.format, c.level)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c.log(l)
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			if len(lines) != len(c.expected) {
+				t.Fatalf("expecting %d lines, got %q", len(c.expected), lines)
+			}
+
+			for i, line := range lines {
+				if !strings.HasSuffix(line, c.expected[i]) {
+					t.Errorf("expecting line ending with %q, got %q", c.expected[i], line)
+				}
+
+				if c.format == LogFormatJSON && !json.Valid([]byte(line)) {
+					t.Errorf("expecting a JSON line, got %q", line)
+				}
+			}
+		})
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	if _, err := NewLogger(&bytes.Buffer{}, "xml", LevelInfo); err != ErrUnknownLogFormat {
+		t.Errorf("expecting unknown log format error, got %v", err)
+	}
+
+	if level, err := ParseLevel("WARN"); err != nil || level != LevelWarn {
+		t.Errorf("expecting warn level, got %s, %v", level, err)
+	}
+
+	if _, err := ParseLevel("trace"); err != ErrUnknownLogLevel {
+		t.Errorf("expecting unknown log level error, got %v", err)
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	var out bytes.Buffer
+	l, _ := NewLogger(&out, LogFormatJSON, LevelInfo)
+	std := log.New(NewLogWriter(l, LevelError), "", 0)
+	std.Printf("sync failed, %s", "timeout")
+	if !strings.HasSuffix(out.String(), `"level":"error","msg":"sync failed, timeout"}`+"\n") {
+		t.Errorf("expecting an error entry, got %q", out.String())
+	}
+}
+
+func TestTransportLoggerRedacts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	l, _ := NewLogger(&out, LogFormatJSON, LevelInfo)
+	cl, err := newReadClient(readClientConfig{
+		address:      strings.Replace(srv.URL, "http://", "http://user:secret@", 1),
+		username:     "elastic",
+		password:     "changeme",
+		logRequests:  true,
+		logResponses: true,
+		logger:       l,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cl.Info(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	logs := out.String()
+	for _, expected := range []string{`"msg":"elasticsearch request"`, `"Authorization: [REDACTED]"`, `"status":200`, `"response_body":"{\"version\": {\"number\": \"7.17.1\"}}"`} {
+		if !strings.Contains(logs, expected) {
+			t.Errorf("expecting %s in %s", expected, logs)
+		}
+	}
+
+	for _, secret := range []string{"secret", "changeme", base64.StdEncoding.EncodeToString([]byte("elastic:changeme")), base64.StdEncoding.EncodeToString([]byte("user:secret"))} {
+		if strings.Contains(logs, secret) {
+			t.Errorf("expecting credentials redacted, got %s", logs)
+		}
+	}
+}
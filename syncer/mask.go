@@ -0,0 +1,356 @@
+package syncer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+const (
+	MaskHash       = "hash"
+	MaskFake       = "fake"
+	MaskTruncateIP = "truncate-ip"
+	MaskNull       = "null"
+
+	defaultIPv4Prefix = 24
+	defaultIPv6Prefix = 48
+)
+
+// IndexPreparer is implemented by transformers depending on the source index setting, e.g. to
+// select fields by mapping type. Prepare is called with every synced index setting before its
+// documents are transformed.
+type IndexPreparer interface {
+	Prepare(setting util.IndexSetting) error
+}
+
+// MaskReporter is implemented by transformers masking document values, MaskReport returns the
+// number of masked values per field.
+type MaskReporter interface {
+	MaskReport() map[string]int64
+}
+
+func init() {
+	RegisterTransform("mask", newMaskTransform)
+}
+
+// maskTransform masks the values of fields selected by dotted path, where `*` matches any object
+// key, or by mapping type in the source index mappings, e.g.
+//
+//	{"type": "mask", "method": "hash", "fields": ["user.email", "user.name"], "key_env": "MASK_KEY"}
+//	{"type": "mask", "method": "truncate-ip", "mapping_types": ["ip"]}
+//
+// Methods are:
+//   - hash, replacing values with their hex HMAC-SHA256, so equal values stay equal;
+//   - fake, replacing letters and digits with letters and digits derived from the HMAC-SHA256 of
+//     the value, keeping case, length and punctuation, e.g. emails stay emails;
+//   - truncate-ip, zeroing IP addresses past the IPv4Prefix and IPv6Prefix bits, values which
+//     aren't IP addresses are nulled;
+//   - null, replacing values with null.
+//
+// The key of hash and fake is set inline, or read from the KeyEnv environment variable.
+type maskTransform struct {
+	Method       string   `json:"method"`
+	Fields       []string `json:"fields"`
+	MappingTypes []string `json:"mapping_types"`
+	Key          string   `json:"key"`
+	KeyEnv       string   `json:"key_env"`
+	IPv4Prefix   int      `json:"ipv4_prefix"`
+	IPv6Prefix   int      `json:"ipv6_prefix"`
+
+	key  []byte
+	mask func(value any) (any, bool)
+
+	mu          sync.RWMutex
+	indexFields map[string][]string
+	counts      map[string]int64
+}
+
+func newMaskTransform(options json.RawMessage) (Transformer, error) {
+	t := &maskTransform{
+		IPv4Prefix:  defaultIPv4Prefix,
+		IPv6Prefix:  defaultIPv6Prefix,
+		indexFields: map[string][]string{},
+		counts:      map[string]int64{},
+	}
+
+	if err := json.Unmarshal(options, t); err != nil {
+		return nil, err
+	}
+
+	if len(t.Fields) == 0 && len(t.MappingTypes) == 0 {
+		return nil, errors.New("either 'fields' or 'mapping_types' is required")
+	}
+
+	if t.KeyEnv != "" {
+		t.Key = os.Getenv(t.KeyEnv)
+	}
+
+	t.key = []byte(t.Key)
+	switch t.Method {
+	case MaskHash:
+		t.mask = t.hash
+	case MaskFake:
+		t.mask = t.fake
+	case MaskTruncateIP:
+		if t.IPv4Prefix < 0 || t.IPv4Prefix > 32 || t.IPv6Prefix < 0 || t.IPv6Prefix > 128 {
+			return nil, errors.New("'ipv4_prefix' must be within 0 and 32, and 'ipv6_prefix' within 0 and 128")
+		}
+
+		t.mask = t.truncateIP
+	case MaskNull:
+		t.mask = func(any) (any, bool) { return nil, true }
+	default:
+		return nil, fmt.Errorf("unknown mask method '%s', expecting one of 'hash', 'fake', 'truncate-ip' or 'null'", t.Method)
+	}
+
+	if (t.Method == MaskHash || t.Method == MaskFake) && len(t.key) == 0 {
+		return nil, errors.New("'key' or 'key_env' is required")
+	}
+
+	return t, nil
+}
+
+// Prepare selects the fields of the index mapped with the mapping types.
+func (t *maskTransform) Prepare(setting util.IndexSetting) error {
+	if len(t.MappingTypes) == 0 {
+		return nil
+	}
+
+	fields := setting.Setting.Mappings.FieldsOfType(t.MappingTypes...)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.indexFields[setting.Index] = fields
+	return nil
+}
+
+func (t *maskTransform) Transform(doc util.Document) (util.Document, error) {
+	t.mu.RLock()
+	fields := append(append([]string{}, t.Fields...), t.indexFields[doc.Index]...)
+	t.mu.RUnlock()
+
+	source, err := decodeSource(doc.Source)
+	if err != nil {
+		return doc, err
+	}
+
+	counts := make(map[string]int64, len(fields))
+	for _, field := range fields {
+		n := maskValues(source, strings.Split(strings.TrimPrefix(field, "$."), "."), t.mask)
+		if n > 0 {
+			counts[field] += int64(n)
+		}
+	}
+
+	if len(counts) == 0 {
+		return doc, nil
+	}
+
+	doc.Source, err = json.Marshal(source)
+	if err != nil {
+		return doc, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for field, n := range counts {
+		t.counts[field] += n
+	}
+
+	return doc, nil
+}
+
+func (t *maskTransform) MaskReport() map[string]int64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	report := make(map[string]int64, len(t.counts))
+	for field, n := range t.counts {
+		report[field] = n
+	}
+
+	return report
+}
+
+// maskValues masks every value at the path, walking objects and arrays, and returns the number of
+// masked values. Objects at the path have all of their values masked.
+func maskValues(v any, names []string, mask func(any) (any, bool)) int {
+	n := 0
+	switch v := v.(type) {
+	case []any:
+		for i, elem := range v {
+			if len(names) == 0 && !isContainer(elem) {
+				n += maskValue(&v[i], mask)
+				continue
+			}
+
+			n += maskValues(elem, names, mask)
+		}
+	case map[string]any:
+		if len(names) == 0 {
+			for key := range v {
+				names = append(names, key)
+			}
+
+			for _, key := range names {
+				n += maskValues(v, []string{key}, mask)
+			}
+
+			return n
+		}
+
+		for key, child := range v {
+			rest, ok := matchKey(key, names)
+			if !ok {
+				continue
+			}
+
+			if len(rest) == 0 && !isContainer(child) {
+				n += maskValue(&child, mask)
+				v[key] = child
+				continue
+			}
+
+			n += maskValues(child, rest, mask)
+		}
+	}
+
+	return n
+}
+
+// matchKey matches the object key against the first names of the path, and returns the remaining
+// names. Keys may be dotted, as `{"user.email": ""}` is mapped like `{"user": {"email": ""}}`, a
+// key longer than the path is within the object at the path, and a trailing `*` matches every
+// remaining part of the key.
+func matchKey(key string, names []string) ([]string, bool) {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		if i == len(names) || names[i] == "*" && i == len(names)-1 {
+			return nil, true
+		}
+
+		if names[i] != "*" && names[i] != part {
+			return nil, false
+		}
+	}
+
+	return names[len(parts):], true
+}
+
+func isContainer(v any) bool {
+	switch v.(type) {
+	case []any, map[string]any:
+		return true
+	}
+
+	return false
+}
+
+func maskValue(v *any, mask func(any) (any, bool)) int {
+	if *v == nil {
+		return 0
+	}
+
+	masked, ok := mask(*v)
+	if !ok {
+		return 0
+	}
+
+	*v = masked
+	return 1
+}
+
+func (t *maskTransform) sum(value string) []byte {
+	h := hmac.New(sha256.New, t.key)
+	h.Write([]byte(value))
+	return h.Sum(nil)
+}
+
+func (t *maskTransform) hash(value any) (any, bool) {
+	str, err := fieldString(value)
+	if err != nil {
+		return nil, false
+	}
+
+	return hex.EncodeToString(t.sum(str)), true
+}
+
+// fake replaces every letter and digit with one derived from the keyed hash of the value. Numbers
+// stay numbers, without leading zero.
+func (t *maskTransform) fake(value any) (any, bool) {
+	str, err := fieldString(value)
+	if err != nil {
+		return nil, false
+	}
+
+	_, number := value.(json.Number)
+	seed := t.sum(str)
+	var counter uint32
+	var stream []byte
+	next := func() byte {
+		if len(stream) == 0 {
+			counter++
+			h := hmac.New(sha256.New, seed)
+			binary.Write(h, binary.BigEndian, counter)
+			stream = h.Sum(nil)
+		}
+
+		b := stream[0]
+		stream = stream[1:]
+		return b
+	}
+
+	leading := true
+	var b strings.Builder
+	for _, r := range str {
+		switch {
+		case unicode.IsDigit(r) && number && leading && r != '0':
+			b.WriteByte('1' + next()%9)
+		case unicode.IsDigit(r):
+			b.WriteByte('0' + next()%10)
+		case number:
+			// signs, the decimal point and the exponent marker are kept so the number stays valid.
+			b.WriteRune(r)
+		case unicode.IsUpper(r):
+			b.WriteByte('A' + next()%26)
+		case unicode.IsLetter(r):
+			b.WriteByte('a' + next()%26)
+		default:
+			b.WriteRune(r)
+		}
+
+		leading = !unicode.IsDigit(r)
+	}
+
+	if number {
+		return json.Number(b.String()), true
+	}
+
+	return b.String(), true
+}
+
+func (t *maskTransform) truncateIP(value any) (any, bool) {
+	str, ok := value.(string)
+	if !ok {
+		return nil, true
+	}
+
+	ip := net.ParseIP(str)
+	switch {
+	case ip == nil:
+		return nil, true
+	case ip.To4() != nil:
+		return ip.Mask(net.CIDRMask(t.IPv4Prefix, 32)).String(), true
+	default:
+		return ip.Mask(net.CIDRMask(t.IPv6Prefix, 128)).String(), true
+	}
+}
@@ -0,0 +1,223 @@
+package syncer
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+func TestMaskTransform(t *testing.T) {
+	transformer, err := ParseTransforms([]byte(`{"transforms": [
+		{"type": "mask", "method": "hash", "fields": ["user.email"], "key": "secret"},
+		{"type": "mask", "method": "fake", "fields": ["user.name", "user.phones", "user.age"], "key": "secret"},
+		{"type": "mask", "method": "truncate-ip", "mapping_types": ["ip"]},
+		{"type": "mask", "method": "null", "fields": ["sessions.*.token"]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mappings util.Mappings
+	if err := json.Unmarshal([]byte(`{"properties": {
+		"client": {"properties": {"ip": {"type": "ip"}}},
+		"server_ip": {"type": "ip"}
+	}}`), &mappings); err != nil {
+		t.Fatal(err)
+	}
+
+	chain := transformer.(TransformChain)
+	if err := chain.Prepare(util.IndexSetting{Index: "logs", Setting: util.SettingInner{Mappings: mappings}}); err != nil {
+		t.Fatal(err)
+	}
+
+	source := json.RawMessage(`{
+		"user": {"email": "john@acme.com", "name": "John Doe", "phones": ["+1 555-0100", null], "age": 42},
+		"client": {"ip": "192.168.10.42"},
+		"server_ip": ["2001:db8:85a3::8a2e:370:7334", "not an ip"],
+		"sessions": {"web": {"token": "abc"}, "mobile": {"token": "def"}}
+	}`)
+
+	masked, err := chain.Transform(util.Document{DocumentMetadata: util.DocumentMetadata{Index: "logs"}, Source: source})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		User struct {
+			Email  string    `json:"email"`
+			Name   string    `json:"name"`
+			Phones []*string `json:"phones"`
+			Age    int       `json:"age"`
+		} `json:"user"`
+		Client struct {
+			IP string `json:"ip"`
+		} `json:"client"`
+		ServerIP []*string                     `json:"server_ip"`
+		Sessions map[string]map[string]*string `json:"sessions"`
+	}
+	if err := json.Unmarshal(masked.Source, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if !regexp.MustCompile(`^[0-9a-f]{64}$`).MatchString(doc.User.Email) {
+		t.Errorf("expecting hashed email, got '%s'", doc.User.Email)
+	}
+
+	if !regexp.MustCompile(`^[A-Z][a-z]{3} [A-Z][a-z]{2}$`).MatchString(doc.User.Name) || doc.User.Name == "John Doe" {
+		t.Errorf("expecting fake name, got '%s'", doc.User.Name)
+	}
+
+	if len(doc.User.Phones) != 2 || doc.User.Phones[1] != nil || !regexp.MustCompile(`^\+\d \d{3}-\d{4}$`).MatchString(*doc.User.Phones[0]) {
+		t.Errorf("expecting fake phone, got %v", doc.User.Phones)
+	}
+
+	if doc.User.Age < 10 || doc.User.Age > 99 {
+		t.Errorf("expecting fake 2 digits age, got %d", doc.User.Age)
+	}
+
+	if doc.Client.IP != "192.168.10.0" {
+		t.Errorf("expecting truncated client IP '192.168.10.0', got '%s'", doc.Client.IP)
+	}
+
+	if len(doc.ServerIP) != 2 || *doc.ServerIP[0] != "2001:db8:85a3::" || doc.ServerIP[1] != nil {
+		t.Errorf("expecting truncated server IPs, got %v", doc.ServerIP)
+	}
+
+	for name, session := range doc.Sessions {
+		if token, ok := session["token"]; !ok || token != nil {
+			t.Errorf("expecting null token of session '%s'", name)
+		}
+	}
+
+	again, err := chain.Transform(util.Document{DocumentMetadata: util.DocumentMetadata{Index: "logs"}, Source: source})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(again.Source) != string(masked.Source) {
+		t.Errorf("expecting deterministic masking, got %s and %s", masked.Source, again.Source)
+	}
+
+	expected := map[string]int64{
+		"user.email":       2,
+		"user.name":        2,
+		"user.phones":      2,
+		"user.age":         2,
+		"client.ip":        2,
+		"server_ip":        4,
+		"sessions.*.token": 4,
+	}
+
+	report := chain.MaskReport()
+	if len(report) != len(expected) {
+		t.Errorf("expecting report %v, got %v", expected, report)
+	}
+
+	for field, n := range expected {
+		if report[field] != n {
+			t.Errorf("expecting %d masked values of field '%s', got %d", n, field, report[field])
+		}
+	}
+}
+
+func TestMaskTransformInvalid(t *testing.T) {
+	for _, options := range []string{
+		`{"type": "mask", "method": "hash", "fields": ["email"]}`,
+		`{"type": "mask", "method": "null"}`,
+		`{"type": "mask", "method": "scramble", "fields": ["email"]}`,
+		`{"type": "mask", "method": "truncate-ip", "fields": ["ip"], "ipv4_prefix": 33}`,
+	} {
+		if _, err := ParseTransforms([]byte(`{"transforms": [` + options + `]}`)); err == nil {
+			t.Errorf("expecting transform %s to be invalid", options)
+		}
+	}
+}
+
+func TestMaskTransformDottedKeys(t *testing.T) {
+	transformer, err := ParseTransforms([]byte(`{"transforms": [
+		{"type": "mask", "method": "null", "fields": ["user.email", "sessions.*.token"]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name     string
+		source   string
+		expected string
+		masked   int64
+	}{
+		{
+			name:     "flat",
+			source:   `{"user.email":"a@b.c","user.name":"John"}`,
+			expected: `{"user.email":null,"user.name":"John"}`,
+			masked:   1,
+		},
+		{
+			name:     "mixed",
+			source:   `{"user.email":"a@b.c","user":{"email":"x@y.z","name":"John"}}`,
+			expected: `{"user":{"email":null,"name":"John"},"user.email":null}`,
+			masked:   2,
+		},
+		{
+			name:     "partially dotted",
+			source:   `{"user":{"email":"x@y.z"},"sessions.web":{"token":"abc"},"sessions":{"mobile.token":"def"}}`,
+			expected: `{"sessions":{"mobile.token":null},"sessions.web":{"token":null},"user":{"email":null}}`,
+			masked:   3,
+		},
+		{
+			name:     "dotted prefix only",
+			source:   `{"user.emails":"a@b.c","user.email.domain":"b.c"}`,
+			expected: `{"user.email.domain":null,"user.emails":"a@b.c"}`,
+			masked:   1,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			chain := transformer.(TransformChain)
+			before := chain.MaskReport()
+			masked, err := chain.Transform(util.Document{DocumentMetadata: util.DocumentMetadata{Index: "logs"}, Source: json.RawMessage(c.source)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(masked.Source) != c.expected {
+				t.Errorf("expecting %s, got %s", c.expected, masked.Source)
+			}
+
+			after := chain.MaskReport()
+			if n := after["user.email"] + after["sessions.*.token"] - before["user.email"] - before["sessions.*.token"]; n != c.masked {
+				t.Errorf("expecting %d masked values, got %d", c.masked, n)
+			}
+		})
+	}
+}
+
+func TestMaskFakeNumbers(t *testing.T) {
+	transformer, err := ParseTransforms([]byte(`{"transforms": [{"type": "mask", "method": "fake", "fields": ["n"], "key": "secret"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digits := regexp.MustCompile(`[0-9]`)
+	for _, n := range []string{"42", "-3.14", "1e5", "1.5E3", "-2.5e-3", "6.02E+23"} {
+		t.Run(n, func(t *testing.T) {
+			doc, err := transformer.Transform(util.Document{Source: json.RawMessage(`{"n": ` + n + `}`)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var masked struct {
+				N json.Number `json:"n"`
+			}
+			if err := json.Unmarshal(doc.Source, &masked); err != nil {
+				t.Fatal(err)
+			}
+
+			if digits.ReplaceAllString(masked.N.String(), "0") != digits.ReplaceAllString(n, "0") {
+				t.Errorf("expecting a number shaped like %s, got %s", n, masked.N)
+			}
+		})
+	}
+}
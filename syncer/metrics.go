@@ -0,0 +1,268 @@
+package syncer
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+)
+
+// bulkLatencyBuckets are the upper bounds in seconds of the bulk request latency histogram.
+var bulkLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Metrics collects sync metrics and serves them in the Prometheus text format, it's safe for
+// concurrent use and meant to be shared by every sync of a process. A nil Metrics collects nothing.
+type Metrics struct {
+	// retries and openPITs are first to be 64-bit aligned for atomic operations.
+	retries  int64
+	openPITs int64
+
+	read    *counterVec
+	indexed *counterVec
+	skipped *counterVec
+	failed  *counterVec
+
+	bulkLatency *histogram
+
+	mu        sync.Mutex
+	bulkStats func() esutil.BulkIndexerStats
+}
+
+// NewMetrics returns empty metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		read:        newCounterVec(),
+		indexed:     newCounterVec(),
+		skipped:     newCounterVec(),
+		failed:      newCounterVec(),
+		bulkLatency: newHistogram(bulkLatencyBuckets),
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	// a write error is a closed connection, there's no one to report it to.
+	m.write(w)
+}
+
+func (m *Metrics) documentRead(index string) {
+	if m != nil {
+		m.read.inc(index)
+	}
+}
+
+func (m *Metrics) documentWritten(index string, result WriteResult) {
+	if m == nil {
+		return
+	}
+
+	if result == WriteSkipped {
+		m.skipped.inc(index)
+		return
+	}
+
+	m.indexed.inc(index)
+}
+
+func (m *Metrics) documentFailed(index string) {
+	if m != nil {
+		m.failed.inc(index)
+	}
+}
+
+func (m *Metrics) retry() {
+	if m != nil {
+		atomic.AddInt64(&m.retries, 1)
+	}
+}
+
+func (m *Metrics) addOpenPIT(delta int64) {
+	if m != nil {
+		atomic.AddInt64(&m.openPITs, delta)
+	}
+}
+
+func (m *Metrics) observeBulk(d time.Duration) {
+	if m != nil {
+		m.bulkLatency.observe(d.Seconds())
+	}
+}
+
+// setBulkIndexer reports the stats of the bulk indexer, replacing the previous one.
+func (m *Metrics) setBulkIndexer(bi esutil.BulkIndexer) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bulkStats = bi.Stats
+}
+
+func (m *Metrics) write(w io.Writer) error {
+	mw := &metricsWriter{w: w}
+	mw.counterVec("elastic_syncer_documents_read_total", "Documents read from the source cluster.", m.read)
+	mw.counterVec("elastic_syncer_documents_indexed_total", "Documents written to the destination cluster.", m.indexed)
+	mw.counterVec("elastic_syncer_documents_skipped_total", "Documents kept on the destination cluster according to the conflict policy.", m.skipped)
+	mw.counterVec("elastic_syncer_documents_failed_total", "Documents failed to be transformed or written.", m.failed)
+	mw.histogram("elastic_syncer_bulk_request_duration_seconds", "Latency of bulk requests to the destination cluster.", m.bulkLatency)
+	mw.value("elastic_syncer_request_retries_total", "Requests to the destination cluster retried.", "counter", float64(atomic.LoadInt64(&m.retries)))
+	mw.value("elastic_syncer_open_point_in_times", "Point-in-times opened on the source cluster and not closed yet.", "gauge", float64(atomic.LoadInt64(&m.openPITs)))
+
+	m.mu.Lock()
+	bulkStats := m.bulkStats
+	m.mu.Unlock()
+	if bulkStats != nil {
+		stats := bulkStats()
+		for _, s := range []struct {
+			name  string
+			help  string
+			value uint64
+		}{
+			{"added", "Documents added to the bulk indexer.", stats.NumAdded},
+			{"flushed", "Documents flushed by the bulk indexer.", stats.NumFlushed},
+			{"failed", "Documents failed to be written by the bulk indexer.", stats.NumFailed},
+			{"indexed", "Documents indexed by the bulk indexer.", stats.NumIndexed},
+			{"created", "Documents created by the bulk indexer.", stats.NumCreated},
+			{"updated", "Documents updated by the bulk indexer.", stats.NumUpdated},
+			{"deleted", "Documents deleted by the bulk indexer.", stats.NumDeleted},
+			{"requests", "Bulk requests sent by the bulk indexer.", stats.NumRequests},
+		} {
+			mw.value(fmt.Sprintf("elastic_syncer_bulk_indexer_%s_total", s.name), s.help, "counter", float64(s.value))
+		}
+	}
+
+	return mw.err
+}
+
+// metricsWriter writes metrics in the Prometheus text format, keeping the first error.
+type metricsWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (mw *metricsWriter) printf(format string, v ...any) {
+	if mw.err == nil {
+		_, mw.err = fmt.Fprintf(mw.w, format, v...)
+	}
+}
+
+func (mw *metricsWriter) header(name, help, kind string) {
+	mw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (mw *metricsWriter) value(name, help, kind string, v float64) {
+	mw.header(name, help, kind)
+	mw.printf("%s %s\n", name, formatFloat(v))
+}
+
+func (mw *metricsWriter) counterVec(name, help string, c *counterVec) {
+	mw.header(name, help, "counter")
+	for _, index := range c.labels() {
+		mw.printf("%s{index=\"%s\"} %d\n", name, labelValueReplacer.Replace(index), c.get(index))
+	}
+}
+
+func (mw *metricsWriter) histogram(name, help string, h *histogram) {
+	mw.header(name, help, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		mw.printf("%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound), cumulative)
+	}
+
+	mw.printf("%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	mw.printf("%s_sum %s\n", name, formatFloat(h.sum))
+	mw.printf("%s_count %d\n", name, h.count)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// counterVec is a counter per index.
+type counterVec struct {
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+func newCounterVec() *counterVec {
+	return &counterVec{values: map[string]uint64{}}
+}
+
+func (c *counterVec) inc(index string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[index]++
+}
+
+func (c *counterVec) get(index string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[index]
+}
+
+// labels returns the indices sorted by name.
+func (c *counterVec) labels() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	labels := make([]string, 0, len(c.values))
+	for index := range c.values {
+		labels = append(labels, index)
+	}
+
+	sort.Strings(labels)
+	return labels
+}
+
+// histogram counts observations per bucket, counts aren't cumulative.
+type histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sum += v
+	h.count++
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		h.counts[i]++
+	}
+}
+
+// metricsTransport observes the latency of bulk requests.
+type metricsTransport struct {
+	next    http.RoundTripper
+	metrics *Metrics
+}
+
+func (t metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/_bulk") {
+		return t.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	t.metrics.observeBulk(time.Since(start))
+	return res, err
+}
@@ -0,0 +1,151 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+func TestMetrics(t *testing.T) {
+	var bulkRequests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/logs/_pit":
+			w.Write([]byte(`{"id": "pit-1"}`))
+			return
+		case r.URL.Path == "/_pit":
+			w.Write([]byte(`{"succeeded": true, "num_freed": 1}`))
+			return
+		case r.URL.Path != "/_bulk":
+			w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+			return
+		}
+
+		b, _ := io.ReadAll(r.Body)
+		if atomic.AddInt64(&bulkRequests, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"type": "es_rejected_execution_exception", "reason": "rejected"}, "status": 429}`))
+			return
+		}
+
+		var items []string
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		for i := 0; i < len(lines); i += 2 {
+			var action struct {
+				Index struct {
+					ID string `json:"_id"`
+				} `json:"index"`
+			}
+			if err := json.Unmarshal([]byte(lines[i]), &action); err != nil {
+				t.Error(err)
+			}
+
+			if action.Index.ID == "invalid" {
+				items = append(items, `{"index": {"_index": "logs", "_id": "invalid", "status": 400, "error": {"type": "mapper_parsing_exception", "reason": "failed to parse"}}}`)
+				continue
+			}
+
+			items = append(items, fmt.Sprintf(`{"index": {"_index": "logs", "_id": "%s", "status": 201, "result": "created"}}`, action.Index.ID))
+		}
+
+		fmt.Fprintf(w, `{"errors": true, "items": [%s]}`, strings.Join(items, ","))
+	}))
+	defer srv.Close()
+
+	metrics := NewMetrics()
+	fromClient, err := newReadClient(readClientConfig{address: srv.URL, metrics: metrics})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toClient, err := newReadWriteClient(readWriteClientConfig{host: srv.URL, metrics: metrics})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	pit, err := fromClient.createPIT(ctx, "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cl := &Client{fromClient: fromClient, toClient: toClient, metrics: metrics, logger: defaultLogger()}
+	onRead := cl.onRead(ctx, nil, "")
+	for _, id := range []string{"1", "2", "invalid"} {
+		onRead(util.Document{DocumentMetadata: util.DocumentMetadata{Index: "logs", ID: id}, Source: json.RawMessage(`{"a":1}`)})
+	}
+
+	if err := toClient.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	toClient.Wait()
+
+	scrape := func() string {
+		metricsSrv := httptest.NewServer(metrics)
+		defer metricsSrv.Close()
+		res, err := http.Get(metricsSrv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer res.Body.Close()
+		if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+			t.Errorf("expecting Prometheus text format, got content type '%s'", ct)
+		}
+
+		b, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(b)
+	}
+
+	body := scrape()
+	for _, expected := range []string{
+		"# TYPE elastic_syncer_documents_read_total counter",
+		`elastic_syncer_documents_read_total{index="logs"} 3`,
+		`elastic_syncer_documents_indexed_total{index="logs"} 2`,
+		`elastic_syncer_documents_failed_total{index="logs"} 1`,
+		"# TYPE elastic_syncer_bulk_request_duration_seconds histogram",
+		`elastic_syncer_bulk_request_duration_seconds_bucket{le="+Inf"} 2`,
+		"elastic_syncer_bulk_request_duration_seconds_count 2",
+		"elastic_syncer_request_retries_total 1",
+		"elastic_syncer_open_point_in_times 1",
+		"elastic_syncer_bulk_indexer_added_total 3",
+		"elastic_syncer_bulk_indexer_indexed_total 2",
+		"elastic_syncer_bulk_indexer_failed_total 1",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expecting '%s' in metrics:\n%s", expected, body)
+		}
+	}
+
+	if err := fromClient.closePIT(ctx, pit); err != nil {
+		t.Fatal(err)
+	}
+
+	if body := scrape(); !strings.Contains(body, "elastic_syncer_open_point_in_times 0") {
+		t.Errorf("expecting no open point-in-time:\n%s", body)
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var metrics *Metrics
+	metrics.documentRead("logs")
+	metrics.documentWritten("logs", WriteCreated)
+	metrics.documentFailed("logs")
+	metrics.retry()
+	metrics.addOpenPIT(1)
+}
@@ -0,0 +1,219 @@
+package syncer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/elastic/go-elasticsearch/v7"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+// DefaultMirrorDeletesThreshold is the default percentage of destination documents above which
+// deletes aren't mirrored.
+const DefaultMirrorDeletesThreshold = 10.0
+
+var (
+	ErrMirrorDeletesLimit = errors.New("deletes can't be mirrored when limiting the number of synced documents")
+
+	// ErrMirrorDeletesID is error returned when mirroring deletes with transforms computing document IDs.
+	ErrMirrorDeletesID = errors.New("deletes can't be mirrored when transforms compute document IDs")
+)
+
+// idScanner pages through the IDs of the documents matching the query, sorted by `_id`, so the IDs of
+// two indices can be compared without holding them in memory. Documents are scanned without
+// `_source` unless source is set.
+type idScanner struct {
+	cl     *elasticsearch.Client
+	index  string
+	query  any
+	size   int
+	source bool
+
+	page  []util.Document
+	after []any
+	done  bool
+}
+
+func newIDScanner(cl *elasticsearch.Client, index string, query any, size int) *idScanner {
+	return &idScanner{cl: cl, index: index, query: query, size: size}
+}
+
+// next returns the next document, ok is false once every document is scanned.
+func (s *idScanner) next(ctx context.Context) (doc util.Document, ok bool, err error) {
+	if len(s.page) == 0 && !s.done {
+		if err := s.fetch(ctx); err != nil {
+			return util.Document{}, false, err
+		}
+	}
+
+	if len(s.page) == 0 {
+		return util.Document{}, false, nil
+	}
+
+	doc, s.page = s.page[0], s.page[1:]
+	return doc, true, nil
+}
+
+func (s *idScanner) fetch(ctx context.Context) error {
+	body := map[string]any{
+		"size":    s.size,
+		"query":   s.query,
+		"sort":    []map[string]string{{"_id": "asc"}},
+		"_source": s.source,
+	}
+
+	if s.after != nil {
+		body["search_after"] = s.after
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.cl.Search(
+		s.cl.Search.WithContext(ctx),
+		s.cl.Search.WithIndex(s.index),
+		s.cl.Search.WithBody(bytes.NewReader(b)),
+	)
+	if err != nil {
+		return err
+	}
+
+	docs, err := util.ParseSearch(res)
+	if err != nil {
+		return err
+	}
+
+	s.page = docs
+	if len(docs) < s.size {
+		s.done = true
+	}
+
+	if len(docs) > 0 {
+		s.after = docs[len(docs)-1].Sort
+	}
+
+	return nil
+}
+
+// missingIDs compares the source and destination IDs, and writes the metadata of every destination
+// document missing on the source to w, one JSON document per line. It returns the number of
+// destination and missing documents.
+func missingIDs(ctx context.Context, source, dest *idScanner, w *bufio.Writer) (total, missing int, err error) {
+	s, sok, err := source.next(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("can not read source IDs, %s", err.Error())
+	}
+
+	enc := json.NewEncoder(w)
+	for {
+		d, dok, err := dest.next(ctx)
+		if err != nil {
+			return 0, 0, fmt.Errorf("can not read destination IDs, %s", err.Error())
+		}
+
+		if !dok {
+			return total, missing, w.Flush()
+		}
+
+		total++
+		for sok && s.ID < d.ID {
+			if s, sok, err = source.next(ctx); err != nil {
+				return 0, 0, fmt.Errorf("can not read source IDs, %s", err.Error())
+			}
+		}
+
+		if sok && s.ID == d.ID {
+			continue
+		}
+
+		missing++
+		if err := enc.Encode(util.DocumentMetadata{Index: d.Index, ID: d.ID, Routing: d.Routing}); err != nil {
+			return 0, 0, err
+		}
+	}
+}
+
+// deleteMissing deletes the destination documents within the sync window which don't exist on the
+// source anymore. Nothing is deleted if more than the threshold percentage of destination documents
+// would be deleted.
+func (c *Client) deleteMissing(ctx context.Context, setting util.IndexSetting) error {
+	req := c.request(setting.Index)
+	req.setDefaults()
+	timeField, err := resolveTimeField(setting.Setting.Mappings, req.timeField)
+	if err != nil {
+		return err
+	}
+
+	req.timeField = timeField
+	dest := c.renames.apply(setting.Index)
+
+	f, err := os.CreateTemp("", "elastic-syncer-deletes-*.ndjson")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	logger := c.logger.With("index", setting.Index)
+	logger.Infof("comparing document IDs with destination index '%s'", dest)
+	total, missing, err := missingIDs(
+		ctx,
+		newIDScanner(c.fromClient.cl, setting.Index, req.query(), req.size),
+		newIDScanner(c.toClient.cl, dest, req.query(), req.size),
+		bufio.NewWriter(f),
+	)
+	if err != nil {
+		return err
+	}
+
+	if missing == 0 {
+		logger.Infof("no document of index '%s' is deleted on source elasticsearch", dest)
+		return nil
+	}
+
+	ratio := float64(missing) * 100 / float64(total)
+	if ratio > c.mirrorDeletesThreshold {
+		return fmt.Errorf("%d of %d documents (%.1f%%) of index '%s' would be deleted, more than the %.1f%% threshold", missing, total, ratio, dest, c.mirrorDeletesThreshold)
+	}
+
+	logger.Infof("deleting %d of %d documents (%.1f%%) of index '%s' deleted on source elasticsearch", missing, total, ratio, dest)
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+
+	counter := c.counter.index(setting.Index)
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var doc util.DocumentMetadata
+		if err := dec.Decode(&doc); err != nil {
+			return err
+		}
+
+		if err := c.toClient.DeleteDocument(
+			ctx,
+			doc,
+			func(doc util.DocumentMetadata) {
+				c.logger.Debugf("done deleting document '%s/%s'", doc.Index, doc.ID)
+				atomic.AddInt64(&counter.deleted, 1)
+			},
+			func(doc util.DocumentMetadata, err error) {
+				logger.Errorf("failed to delete document '%s/%s', %s", doc.Index, doc.ID, err.Error())
+				counter.failDelete(fmt.Sprintf("failed to delete document '%s', %s", doc.ID, err.Error()))
+			},
+		); err != nil {
+			return fmt.Errorf("can not delete document '%s/%s', %s", doc.Index, doc.ID, err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package syncer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v7"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+// idServer serves the IDs sorted by `_id`, paginated with search_after.
+func idServer(t *testing.T, index string, ids []string) *httptest.Server {
+	sort.Strings(ids)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/" {
+			w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+			return
+		}
+
+		var body struct {
+			Size  int      `json:"size"`
+			After []string `json:"search_after"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+
+		start := 0
+		if len(body.After) > 0 {
+			start = sort.SearchStrings(ids, body.After[0]) + 1
+		}
+
+		end := start + body.Size
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		hits := []string{}
+		for _, id := range ids[start:end] {
+			hits = append(hits, fmt.Sprintf(`{"_index": "%s", "_id": "%s", "sort": ["%s"]}`, index, id, id))
+		}
+
+		fmt.Fprintf(w, `{"hits": {"total": {"value": %d, "relation": "eq"}, "hits": [%s]}}`, len(ids), strings.Join(hits, ","))
+	}))
+}
+
+func TestMissingIDs(t *testing.T) {
+	source := idServer(t, "logs", []string{"a", "b", "d", "f", "g", "h"})
+	defer source.Close()
+
+	dest := idServer(t, "logs-copy", []string{"a", "c", "d", "e", "g", "z"})
+	defer dest.Close()
+
+	scanner := func(url, index string) *idScanner {
+		cl, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{url}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return newIDScanner(cl, index, map[string]any{"match_all": map[string]any{}}, 2)
+	}
+
+	var b bytes.Buffer
+	total, missing, err := missingIDs(context.Background(), scanner(source.URL, "logs"), scanner(dest.URL, "logs-copy"), bufio.NewWriter(&b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if total != 6 || missing != 3 {
+		t.Errorf("expecting 3 of 6 missing documents, got %d of %d", missing, total)
+	}
+
+	expected := `{"_index":"logs-copy","_id":"c"}
+{"_index":"logs-copy","_id":"e"}
+{"_index":"logs-copy","_id":"z"}
+`
+	if b.String() != expected {
+		t.Errorf("expecting missing documents\n%s\ngot\n%s", expected, b.String())
+	}
+}
+
+func TestNewMirrorDeletes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+	}))
+	defer srv.Close()
+
+	computeID, err := ParseTransforms([]byte(`{"transforms": [{"type": "id", "fields": ["user.id"]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zero := 0.0
+	for _, c := range []struct {
+		name         string
+		limit        int
+		transformers []Transformer
+		threshold    *float64
+		expected     float64
+		err          error
+	}{
+		{name: "default threshold", expected: DefaultMirrorDeletesThreshold},
+		{name: "zero threshold", threshold: &zero, expected: 0},
+		{name: "limit", limit: 10, err: ErrMirrorDeletesLimit},
+		{name: "computed ID", transformers: []Transformer{computeID}, err: ErrMirrorDeletesID},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cl, err := New(Config{
+				FromHost:               srv.URL,
+				ToHost:                 srv.URL,
+				Limit:                  c.limit,
+				Transformers:           c.transformers,
+				MirrorDeletes:          true,
+				MirrorDeletesThreshold: c.threshold,
+			})
+			if err != c.err {
+				t.Fatalf("expecting error %v, got %v", c.err, err)
+			}
+
+			if err == nil && cl.mirrorDeletesThreshold != c.expected {
+				t.Errorf("expecting threshold %.1f, got %.1f", c.expected, cl.mirrorDeletesThreshold)
+			}
+		})
+	}
+}
+
+func TestDeleteMissingCounts(t *testing.T) {
+	source := idServer(t, "logs", []string{"a", "b"})
+	defer source.Close()
+
+	destIDs := idServer(t, "logs", []string{"a", "b", "c", "d"})
+	defer destIDs.Close()
+
+	dest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" {
+			destIDs.Config.Handler.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"errors": true, "items": [
+			{"delete": {"_index": "logs", "_id": "c", "status": 200, "result": "deleted"}},
+			{"delete": {"_index": "logs", "_id": "d", "status": 403, "error": {"type": "cluster_block_exception", "reason": "index [logs] blocked"}}}
+		]}`))
+	}))
+	defer dest.Close()
+
+	fromClient, err := newReadClient(readClientConfig{address: source.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toClient, err := newReadWriteClient(readWriteClientConfig{host: dest.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cl := &Client{
+		fromClient:             fromClient,
+		toClient:               toClient,
+		counter:                newSyncCounter(map[string]string{"logs": "logs"}),
+		logger:                 defaultLogger(),
+		mirrorDeletesThreshold: 100,
+	}
+
+	ctx := context.Background()
+	if err := cl.deleteMissing(ctx, util.IndexSetting{Index: "logs"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := toClient.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	toClient.Wait()
+	result := cl.counter.result()
+	if i := result.Indices[0]; i.Deleted != 1 || i.DeleteFailed != 1 || len(i.Errors) != 1 {
+		t.Errorf("expecting 1 deleted and 1 failed delete, got %+v", i)
+	}
+
+	if err := result.Check(0); err != ErrPartialFailure {
+		t.Errorf("expecting partial failure, got %v", err)
+	}
+}
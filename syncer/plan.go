@@ -0,0 +1,211 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+// Plan actions on destination indices.
+const (
+	PlanCreate = "create"
+	// PlanSkip is an index existing on the destination, so it's not created.
+	PlanSkip = "skip"
+)
+
+// Plan is what Sync would do, without writing anything. ReconcileMappings is whether unmapped
+// fields of existing destination indices would be added to their mappings.
+type Plan struct {
+	From              time.Time   `json:"from"`
+	To                time.Time   `json:"to"`
+	Indices           []IndexPlan `json:"indices"`
+	Documents         int64       `json:"documents"`
+	StoreSize         int64       `json:"store_size_in_bytes"`
+	ReconcileMappings bool        `json:"reconcile_mappings"`
+}
+
+// IndexPlan is the plan of a source index. Documents is the number of documents matching the time
+// window and query, and StoreSize is the store size of these documents, estimated from the index
+// store size per document.
+type IndexPlan struct {
+	Index       string `json:"index"`
+	Destination string `json:"destination"`
+	Action      string `json:"action"`
+	ReadMode    string `json:"read_mode"`
+	TimeField   string `json:"time_field"`
+	Documents   int64  `json:"documents"`
+	StoreSize   int64  `json:"store_size_in_bytes"`
+
+	// Synced is an index completely synced according to the state file, it's not read when resuming.
+	Synced bool `json:"synced,omitempty"`
+
+	// MappingConflicts are fields mapped with another type on the existing destination index, Sync
+	// aborts on them. UnmappedFields are source fields the existing destination index doesn't map.
+	MappingConflicts []util.MappingConflict `json:"mapping_conflicts,omitempty"`
+	UnmappedFields   []string               `json:"unmapped_fields,omitempty"`
+}
+
+// Plan resolves the indices to sync, and returns whether their destination index would be
+// created, how they would be read, an estimate of the documents to sync, and how the mappings of
+// existing destination indices differ.
+func (c *Client) Plan(ctx context.Context) (Plan, error) {
+	plan := Plan{From: c.from, To: c.to, ReconcileMappings: c.reconcileMappings}
+
+	c.logger.Infof("reading index settings for '%s'", c.index)
+	settings, err := c.fromClient.ReadIndexSettings(ctx, c.index)
+	if err != nil {
+		return plan, fmt.Errorf("can not get index settings for '%s', %s", c.index, err.Error())
+	}
+
+	if len(c.filter) > 0 || c.queryString != "" {
+		c.logger.Infof("validating query on '%s'", c.index)
+		req := readAllRequest{index: c.index, filter: c.filter, queryString: c.queryString}
+		if err := c.fromClient.ValidateQuery(ctx, req); err != nil {
+			return plan, fmt.Errorf("can not validate query, %s", err.Error())
+		}
+	}
+
+	stats, err := c.fromClient.IndexStats(ctx, c.index)
+	if err != nil {
+		return plan, fmt.Errorf("can not get index stats for '%s', %s", c.index, err.Error())
+	}
+
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Index < settings[j].Index })
+	for _, setting := range settings {
+		req := c.request(setting.Index)
+		req.setDefaults()
+		if req.timeField, err = resolveTimeField(setting.Setting.Mappings, req.timeField); err != nil {
+			return plan, fmt.Errorf("can not plan index '%s', %s", setting.Index, err.Error())
+		}
+
+		p := IndexPlan{
+			Index:       setting.Index,
+			Destination: c.renames.apply(setting.Index),
+			Action:      PlanCreate,
+			TimeField:   req.timeField,
+		}
+
+		exist, err := c.toClient.IndexExist(ctx, p.Destination)
+		if err != nil {
+			return plan, fmt.Errorf("can not check index exist for '%s', %s", p.Destination, err.Error())
+		}
+
+		if exist {
+			p.Action = PlanSkip
+			dest, err := c.toClient.GetMapping(ctx, p.Destination)
+			if err != nil {
+				return plan, fmt.Errorf("can not get mappings of index '%s' on destination elasticsearch, %s", p.Destination, err.Error())
+			}
+
+			diff := util.DiffMappings(c.destinationSetting(setting).Setting.Mappings, dest)
+			p.MappingConflicts, p.UnmappedFields = diff.Conflicts, diff.Added
+		}
+
+		if p.ReadMode, err = c.fromClient.ReadMode(ctx, req.timeField); err != nil {
+			return plan, err
+		}
+
+		if c.checkpoints != nil && c.resume && p.ReadMode == ReadModePIT {
+			prev, ok := c.checkpoints.get(setting.Index)
+			p.Synced = ok && prev.Complete && prev.TimeField == req.timeField
+		}
+
+		if !p.Synced {
+			if p.Documents, err = c.fromClient.Count(ctx, setting.Index, req.query()); err != nil {
+				return plan, fmt.Errorf("can not count documents of index '%s', %s", setting.Index, err.Error())
+			}
+
+			if c.limit > 0 && p.Documents > int64(c.limit) {
+				p.Documents = int64(c.limit)
+			}
+
+			s := stats[setting.Index]
+			p.StoreSize = estimateStoreSize(s.Store.SizeInBytes, s.Docs.Count, p.Documents)
+		}
+
+		plan.Indices = append(plan.Indices, p)
+		plan.Documents += p.Documents
+		plan.StoreSize += p.StoreSize
+	}
+
+	return plan, nil
+}
+
+// WriteText writes the plan as a table.
+func (p Plan) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "syncing from '%s' to '%s'\n\n", p.From.Format(time.RFC3339), p.To.Format(time.RFC3339))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "INDEX\tDESTINATION\tACTION\tREAD MODE\tTIME FIELD\tDOCUMENTS\tSTORE SIZE")
+	for _, i := range p.Indices {
+		readMode, timeField := i.ReadMode, i.TimeField
+		if i.Synced {
+			readMode = "synced"
+		}
+
+		if timeField == "" {
+			timeField = "-"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", i.Index, i.Destination, i.Action, readMode, timeField, i.Documents, formatBytes(i.StoreSize))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, i := range p.Indices {
+		if len(i.MappingConflicts) > 0 {
+			fmt.Fprintf(w, "\nmappings of '%s' have %d conflicting fields, the sync would abort:\n", i.Destination, len(i.MappingConflicts))
+			for _, c := range i.MappingConflicts {
+				fmt.Fprintf(w, "  ! %s: '%s' on source, '%s' on destination\n", c.Field, c.Source, c.Destination)
+			}
+		}
+
+		if len(i.UnmappedFields) > 0 {
+			action := "use reconcile mappings to add them"
+			if p.ReconcileMappings {
+				action = "they would be added"
+			}
+
+			fmt.Fprintf(w, "\n%d fields aren't mapped on '%s', %s:\n", len(i.UnmappedFields), i.Destination, action)
+			for _, field := range i.UnmappedFields {
+				fmt.Fprintf(w, "  + %s\n", field)
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d indices, %d documents, %s\n", len(p.Indices), p.Documents, formatBytes(p.StoreSize))
+	return err
+}
+
+// estimateStoreSize returns the store size of the documents, from the store size and document count
+// of the whole index. It's computed as float, multiplying the sizes of large indices overflows.
+func estimateStoreSize(size, count, documents int64) int64 {
+	if count <= 0 {
+		return 0
+	}
+
+	return int64(float64(size) / float64(count) * float64(documents))
+}
+
+// formatBytes formats the size with a binary unit, as elasticsearch does.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%db", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 4; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%cb", float64(n)/float64(div), "kmgtp"[exp])
+}
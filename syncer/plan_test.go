@@ -0,0 +1,183 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+func TestPlan(t *testing.T) {
+	var counted []string
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+		case "/logs-*":
+			w.Write([]byte(`{
+				"logs-1": {"aliases": {}, "mappings": {"properties": {"@timestamp": {"type": "date"}, "status": {"type": "keyword"}, "message": {"type": "text"}}}, "settings": {}},
+				"logs-meta": {"aliases": {}, "mappings": {"properties": {"name": {"type": "keyword"}}}, "settings": {}}
+			}`))
+		case "/logs-*/_stats/docs,store":
+			w.Write([]byte(`{"indices": {
+				"logs-1": {"primaries": {"docs": {"count": 100}, "store": {"size_in_bytes": 204800}}},
+				"logs-meta": {"primaries": {"docs": {"count": 0}, "store": {"size_in_bytes": 225}}}
+			}}`))
+		case "/logs-1/_count", "/logs-meta/_count":
+			var body struct {
+				Query json.RawMessage `json:"query"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Error(err)
+			}
+
+			counted = append(counted, string(body.Query))
+			if strings.HasPrefix(r.URL.Path, "/logs-1") {
+				w.Write([]byte(`{"count": 25}`))
+				return
+			}
+
+			w.Write([]byte(`{"count": 0}`))
+		default:
+			t.Errorf("unexpected source request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer source.Close()
+
+	dest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodHead && r.Method != http.MethodGet {
+			t.Errorf("unexpected destination request %s %s", r.Method, r.URL.Path)
+		}
+
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+		case "/copy-1":
+			w.WriteHeader(http.StatusOK)
+		case "/copy-1/_mapping":
+			w.Write([]byte(`{"copy-1": {"mappings": {"properties": {"@timestamp": {"type": "date"}, "status": {"type": "long"}}}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer dest.Close()
+
+	cl, err := New(Config{
+		Index:    "logs-*",
+		Since:    time.Hour,
+		Rename:   []string{"prefix:logs-=copy-"},
+		FromHost: source.URL,
+		ToHost:   dest.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := cl.Plan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []IndexPlan{
+		{
+			Index: "logs-1", Destination: "copy-1", Action: PlanSkip, ReadMode: ReadModePIT, TimeField: "@timestamp", Documents: 25, StoreSize: 51200,
+			MappingConflicts: []util.MappingConflict{{Field: "status", Source: "keyword", Destination: "long"}},
+			UnmappedFields:   []string{"message"},
+		},
+		{Index: "logs-meta", Destination: "copy-meta", Action: PlanCreate, ReadMode: ReadModePaginate},
+	}
+
+	if len(plan.Indices) != len(expected) {
+		t.Fatalf("expecting %d indices, got %+v", len(expected), plan.Indices)
+	}
+
+	for i, p := range expected {
+		if !reflect.DeepEqual(plan.Indices[i], p) {
+			t.Errorf("expecting plan %+v, got %+v", p, plan.Indices[i])
+		}
+	}
+
+	if plan.Documents != 25 || plan.StoreSize != 51200 {
+		t.Errorf("expecting 25 documents of 51200 bytes, got %d documents of %d bytes", plan.Documents, plan.StoreSize)
+	}
+
+	if len(counted) != 2 || !strings.Contains(counted[0], `"range":{"@timestamp"`) || strings.Contains(counted[1], `"range"`) {
+		t.Errorf("expecting documents counted within the time window of the time field, got %v", counted)
+	}
+
+	var buf bytes.Buffer
+	if err := plan.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		"logs-1 copy-1 skip pit @timestamp 25 50.0kb",
+		"logs-meta copy-meta create paginate - 0 0b",
+		"mappings of 'copy-1' have 1 conflicting fields, the sync would abort:",
+		"! status: 'keyword' on source, 'long' on destination",
+		"1 fields aren't mapped on 'copy-1', use reconcile mappings to add them:",
+		"+ message",
+		"2 indices, 25 documents, 50.0kb",
+	} {
+		found := false
+		for _, l := range strings.Split(buf.String(), "\n") {
+			if strings.Join(strings.Fields(l), " ") == line {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Errorf("expecting line '%s' in plan:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestEstimateStoreSize(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		size      int64
+		count     int64
+		documents int64
+		expected  int64
+	}{
+		{name: "no documents", size: 1024, expected: 0},
+		{name: "half", size: 1024, count: 10, documents: 5, expected: 512},
+		{name: "all", size: 1024, count: 10, documents: 10, expected: 1024},
+		{name: "large", size: 50 << 40, count: 10e9, documents: 5e9, expected: 25 << 40},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if size := estimateStoreSize(c.size, c.count, c.documents); size != c.expected {
+				t.Errorf("expecting %d bytes, got %d", c.expected, size)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for _, c := range []struct {
+		n        int64
+		expected string
+	}{
+		{n: 0, expected: "0b"},
+		{n: 1023, expected: "1023b"},
+		{n: 1536, expected: "1.5kb"},
+		{n: 5 * 1024 * 1024, expected: "5.0mb"},
+		{n: 3 * 1024 * 1024 * 1024, expected: "3.0gb"},
+	} {
+		if s := formatBytes(c.n); s != c.expected {
+			t.Errorf("expecting %d bytes formatted as '%s', got '%s'", c.n, c.expected, s)
+		}
+	}
+}
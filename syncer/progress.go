@@ -0,0 +1,283 @@
+package syncer
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultProgressInterval is the default interval between progress log entries.
+	DefaultProgressInterval = 10 * time.Second
+
+	// progressTTYInterval is the interval between progress line updates on a terminal.
+	progressTTYInterval = time.Second
+
+	// progressTTYIndices is the maximum number of indices shown on the progress line.
+	progressTTYIndices = 3
+
+	// clearLine moves the cursor to the start of the line and clears it.
+	clearLine = "\r\033[K"
+)
+
+// Terminal writes log entries to a terminal below which the sync progress is a single updating
+// line, the progress line is cleared before every entry and redrawn after it. Loggers writing to
+// the same terminal must write through it, so entries don't break the progress line.
+type Terminal struct {
+	mu   sync.Mutex
+	out  io.Writer
+	line string
+}
+
+// NewTerminal returns a terminal writing to f, or nil if f isn't a terminal.
+func NewTerminal(f *os.File) *Terminal {
+	if !isTerminal(f) {
+		return nil
+	}
+
+	return &Terminal{out: f}
+}
+
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.line == "" {
+		return t.out.Write(p)
+	}
+
+	if _, err := io.WriteString(t.out, clearLine); err != nil {
+		return 0, err
+	}
+
+	n, err := t.out.Write(p)
+	if err != nil {
+		return n, err
+	}
+
+	_, err = io.WriteString(t.out, t.line)
+	return n, err
+}
+
+// setLine replaces the progress line.
+func (t *Terminal) setLine(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.line = line
+	io.WriteString(t.out, clearLine+line)
+}
+
+// endLine ends the progress line, it's kept as is above the following entries.
+func (t *Terminal) endLine() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.line != "" {
+		io.WriteString(t.out, "\n")
+		t.line = ""
+	}
+}
+
+// indexProgress is the progress of an index, Processed are documents written, skipped or failed.
+// ETA is negative if it's unknown.
+type indexProgress struct {
+	Index       string
+	Read        int64
+	Processed   int64
+	Failed      int64
+	Total       int64
+	DocsPerSec  float64
+	BytesPerSec float64
+	ETA         time.Duration
+}
+
+// fields returns the progress as log fields.
+func (p indexProgress) fields() []any {
+	return []any{
+		"read", p.Read,
+		"processed", p.Processed,
+		"failed", p.Failed,
+		"total", p.Total,
+		"docs_per_sec", math.Round(p.DocsPerSec*10) / 10,
+		"mb_per_sec", math.Round(p.BytesPerSec/1e4) / 100,
+		"eta", p.eta(),
+	}
+}
+
+func (p indexProgress) eta() string {
+	if p.ETA < 0 {
+		return "unknown"
+	}
+
+	return p.ETA.Round(time.Second).String()
+}
+
+// progressReporter reports the progress of every index, either as a single updating line on the
+// terminal if it's set, or as log entries.
+type progressReporter struct {
+	counter  *syncCounter
+	inFlight func() int64
+	terminal *Terminal
+	logger   Logger
+
+	start time.Time
+	last  time.Time
+	prev  map[string]IndexResult
+}
+
+func newProgressReporter(counter *syncCounter, inFlight func() int64, terminal *Terminal, logger Logger) *progressReporter {
+	now := time.Now()
+	return &progressReporter{
+		counter:  counter,
+		inFlight: inFlight,
+		terminal: terminal,
+		logger:   logger,
+		start:    now,
+		last:     now,
+		prev:     map[string]IndexResult{},
+	}
+}
+
+// progress returns the progress of every index since the previous call, and the total progress.
+func (p *progressReporter) progress(now time.Time) ([]indexProgress, indexProgress) {
+	result := p.counter.result()
+	elapsed, sinceStart := now.Sub(p.last).Seconds(), now.Sub(p.start).Seconds()
+	total := indexProgress{Index: "total"}
+	var indices []indexProgress
+	for _, i := range result.Indices {
+		prev := p.prev[i.Index]
+		ip := indexProgress{
+			Index:     i.Index,
+			Read:      i.Read,
+			Processed: i.Written + i.Skipped + i.Failed,
+			Failed:    i.Failed,
+			Total:     i.Total,
+		}
+
+		if elapsed > 0 {
+			ip.DocsPerSec = float64(ip.Processed-(prev.Written+prev.Skipped+prev.Failed)) / elapsed
+			ip.BytesPerSec = float64(i.Bytes-prev.Bytes) / elapsed
+		}
+
+		ip.ETA = estimate(ip.Processed, ip.Total, sinceStart)
+		indices = append(indices, ip)
+		p.prev[i.Index] = i
+
+		total.Read += ip.Read
+		total.Processed += ip.Processed
+		total.Failed += ip.Failed
+		total.Total += ip.Total
+		total.DocsPerSec += ip.DocsPerSec
+		total.BytesPerSec += ip.BytesPerSec
+	}
+
+	total.ETA = estimate(total.Processed, total.Total, sinceStart)
+	p.last = now
+	return indices, total
+}
+
+// estimate returns the remaining time at the average rate since start, or -1 if it's unknown.
+func estimate(processed, total int64, sinceStart float64) time.Duration {
+	if processed >= total && total > 0 {
+		return 0
+	}
+
+	if processed == 0 || total == 0 || sinceStart <= 0 {
+		return -1
+	}
+
+	rate := float64(processed) / sinceStart
+	return time.Duration(float64(total-processed) / rate * float64(time.Second))
+}
+
+// report writes the progress, indices without progress since the previous report are omitted
+// from log entries and from the progress line.
+func (p *progressReporter) report(now time.Time) {
+	prev := make(map[string]int64, len(p.prev))
+	for index, i := range p.prev {
+		prev[index] = i.Read + i.Written + i.Skipped + i.Failed
+	}
+
+	indices, total := p.progress(now)
+	active := indices[:0:0]
+	for _, i := range indices {
+		if last, ok := prev[i.Index]; !ok || last != i.Read+i.Processed {
+			active = append(active, i)
+		}
+	}
+
+	if p.terminal != nil {
+		p.terminal.setLine(p.line(total, active))
+		return
+	}
+
+	for _, i := range active {
+		p.logger.With("index", i.Index).With(i.fields()...).Infof("progress")
+	}
+
+	p.logger.With("index", "*").With(total.fields()...).With("in_flight_bytes", p.inFlight()).Infof("progress")
+}
+
+// line returns the progress line, with the total progress followed by the progress of the active
+// indices.
+func (p *progressReporter) line(total indexProgress, active []indexProgress) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "read %d, processed %d/%d, failed %d | %.0f docs/s, %.2f MB/s | in-flight %s | ETA %s",
+		total.Read, total.Processed, total.Total, total.Failed, total.DocsPerSec, total.BytesPerSec/1e6, formatBytes(p.inFlight()), total.eta())
+	for n, i := range active {
+		if n == progressTTYIndices {
+			fmt.Fprintf(&b, ", +%d more", len(active)-n)
+			break
+		}
+
+		sep := ", "
+		if n == 0 {
+			sep = " | "
+		}
+
+		fmt.Fprintf(&b, "%s%s %d/%d ETA %s", sep, i.Index, i.Processed, i.Total, i.eta())
+	}
+
+	return b.String()
+}
+
+// reportProgress reports the progress every interval, or every second on the terminal, until the
+// returned stop function is called.
+func (c *Client) reportProgress(interval time.Duration) (stop func()) {
+	if c.terminal != nil {
+		interval = progressTTYInterval
+	}
+
+	p := newProgressReporter(c.counter, c.toClient.InFlightBytes, c.terminal, c.logger)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				p.report(now)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		p.report(time.Now())
+		if c.terminal != nil {
+			c.terminal.endLine()
+		}
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,160 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+func TestEstimate(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		processed  int64
+		total      int64
+		sinceStart float64
+		eta        time.Duration
+	}{
+		{name: "unknown total", processed: 10, sinceStart: 1, eta: -1},
+		{name: "nothing processed", total: 10, sinceStart: 1, eta: -1},
+		{name: "done", processed: 10, total: 10, sinceStart: 1, eta: 0},
+		{name: "half", processed: 50, total: 100, sinceStart: 10, eta: 10 * time.Second},
+		{name: "quarter", processed: 25, total: 100, sinceStart: 5, eta: 15 * time.Second},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if eta := estimate(c.processed, c.total, c.sinceStart); eta != c.eta {
+				t.Errorf("expecting %s, got %s", c.eta, eta)
+			}
+		})
+	}
+}
+
+func TestProgressReport(t *testing.T) {
+	counter := newSyncCounter(map[string]string{"logs": "logs", "metrics": "metrics"})
+	logs := counter.index("logs")
+	atomic.StoreInt64(&logs.total, 100)
+	atomic.StoreInt64(&logs.read, 60)
+	atomic.StoreInt64(&logs.written, 40)
+	atomic.StoreInt64(&logs.failed, 10)
+	atomic.StoreInt64(&logs.bytes, 2e6)
+
+	var out bytes.Buffer
+	p := newProgressReporter(counter, func() int64 { return 1536 }, &Terminal{out: &out}, defaultLogger())
+	p.start = p.start.Add(-10 * time.Second)
+	p.last = p.start
+
+	now := p.start.Add(10 * time.Second)
+	indices, total := p.progress(now)
+	if len(indices) != 2 {
+		t.Fatalf("expecting 2 indices, got %+v", indices)
+	}
+
+	if i := indices[0]; i.Index != "logs" || i.Processed != 50 || i.DocsPerSec != 5 || i.BytesPerSec != 2e5 || i.ETA != 10*time.Second {
+		t.Errorf("expecting 50 processed documents at 5 docs/s and 200kb/s, got %+v", i)
+	}
+
+	if i := indices[1]; i.ETA != -1 {
+		t.Errorf("expecting unknown ETA without total, got %+v", i)
+	}
+
+	if total.Total != 100 || total.Processed != 50 || total.Read != 60 {
+		t.Errorf("expecting total progress, got %+v", total)
+	}
+
+	atomic.AddInt64(&logs.written, 10)
+	p.report(now.Add(time.Second))
+	line := out.String()
+	if !strings.HasPrefix(line, "\r\033[K") || strings.Contains(line, "\n") {
+		t.Errorf("expecting a single updating line, got %q", line)
+	}
+
+	for _, expected := range []string{"read 60", "processed 60/100", "failed 10", "10 docs/s", "in-flight 1.5kb", "| logs 60/100 ETA"} {
+		if !strings.Contains(line, expected) {
+			t.Errorf("expecting %q in %q", expected, line)
+		}
+	}
+
+	if strings.Contains(line, "metrics") {
+		t.Errorf("expecting index without progress omitted, got %q", line)
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	var out bytes.Buffer
+	term := &Terminal{out: &out}
+	l, _ := NewLogger(term, LogFormatText, LevelInfo)
+
+	l.Infof("before progress")
+	term.setLine("read 10")
+	l.Warnf("slow")
+	term.setLine("read 20")
+	term.endLine()
+	l.Infof("after progress")
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expecting 4 lines, got %q", lines)
+	}
+
+	if !strings.HasSuffix(lines[0], "INFO before progress") || strings.Contains(lines[0], "\r") {
+		t.Errorf("expecting entry without progress line, got %q", lines[0])
+	}
+
+	// the progress line is cleared before the entry, and redrawn after it.
+	if !strings.HasPrefix(lines[1], "\r\033[Kread 10\r\033[K") || !strings.HasSuffix(lines[1], "WARN slow") {
+		t.Errorf("expecting progress line cleared before entry, got %q", lines[1])
+	}
+
+	if lines[2] != "read 10\r\033[Kread 20" {
+		t.Errorf("expecting progress line redrawn and updated, got %q", lines[2])
+	}
+
+	if !strings.HasSuffix(lines[3], "INFO after progress") || strings.Contains(lines[3], "\r") {
+		t.Errorf("expecting entry below the ended progress line, got %q", lines[3])
+	}
+}
+
+func TestCountTotalResumed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/logs/_count" {
+			w.Write([]byte(`{"count": 100}`))
+			return
+		}
+
+		w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+	}))
+	defer srv.Close()
+
+	fromClient, err := newReadClient(readClientConfig{address: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name     string
+		resumed  int64
+		limit    int
+		expected int64
+	}{
+		{name: "not resumed", expected: 100},
+		{name: "resumed", resumed: 40, expected: 60},
+		{name: "resumed with limit", resumed: 40, limit: 50, expected: 50},
+		{name: "resumed more than counted", resumed: 120, expected: 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cl := &Client{fromClient: fromClient, counter: newSyncCounter(map[string]string{"logs": "logs"}), logger: defaultLogger()}
+			cl.countTotal(context.Background(), readAllRequest{index: "logs", limit: c.limit}, util.IndexSetting{Index: "logs"}, c.resumed)
+			if total := atomic.LoadInt64(&cl.counter.index("logs").total); total != c.expected {
+				t.Errorf("expecting total %d, got %d", c.expected, total)
+			}
+		})
+	}
+}
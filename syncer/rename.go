@@ -0,0 +1,91 @@
+package syncer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	renamePrefix = "prefix:"
+	renameSuffix = "suffix:"
+)
+
+// renameRule renames a source index to its destination index, ok is false if the rule doesn't
+// match the index.
+type renameRule func(index string) (renamed string, ok bool)
+
+// renameRules renames source indices with the first matching rule, indices not matching any rule
+// keep their name.
+type renameRules []renameRule
+
+// parseRenameRules parses rules formatted as `pattern=replacement`, where pattern is a regular
+// expression matching the whole index name and replacement may refer to its capture groups, e.g.
+// `logs-prod-(.*)=logs-staging-$1`. Rules prefixed with `prefix:` or `suffix:` replace the index
+// name prefix or suffix instead, e.g. `prefix:logs-prod-=logs-staging-`.
+func parseRenameRules(rules []string) (renameRules, error) {
+	var renames renameRules
+	for _, rule := range rules {
+		r, err := parseRenameRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rename rule '%s', %s", rule, err.Error())
+		}
+
+		renames = append(renames, r)
+	}
+
+	return renames, nil
+}
+
+func parseRenameRule(rule string) (renameRule, error) {
+	i := strings.LastIndex(rule, "=")
+	if i < 0 {
+		return nil, fmt.Errorf("expecting 'pattern=replacement'")
+	}
+
+	pattern, replacement := rule[:i], rule[i+1:]
+	switch {
+	case strings.HasPrefix(pattern, renamePrefix):
+		prefix := strings.TrimPrefix(pattern, renamePrefix)
+		return func(index string) (string, bool) {
+			if !strings.HasPrefix(index, prefix) {
+				return "", false
+			}
+
+			return replacement + strings.TrimPrefix(index, prefix), true
+		}, nil
+	case strings.HasPrefix(pattern, renameSuffix):
+		suffix := strings.TrimPrefix(pattern, renameSuffix)
+		return func(index string) (string, bool) {
+			if !strings.HasSuffix(index, suffix) {
+				return "", false
+			}
+
+			return strings.TrimSuffix(index, suffix) + replacement, true
+		}, nil
+	}
+
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, err
+	}
+
+	return func(index string) (string, bool) {
+		if !re.MatchString(index) {
+			return "", false
+		}
+
+		return re.ReplaceAllString(index, replacement), true
+	}, nil
+}
+
+// apply returns the destination index name of the source index.
+func (r renameRules) apply(index string) string {
+	for _, rule := range r {
+		if renamed, ok := rule(index); ok {
+			return renamed
+		}
+	}
+
+	return index
+}
@@ -0,0 +1,36 @@
+package syncer
+
+import "testing"
+
+func TestRenameRules(t *testing.T) {
+	renames, err := parseRenameRules([]string{
+		`logs-prod-(\d{4})\.(\d{2})=logs-staging-$2-$1`,
+		"prefix:metrics-prod-=metrics-staging-",
+		"suffix:-prod=-staging",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		index    string
+		expected string
+	}{
+		{index: "logs-prod-2024.05", expected: "logs-staging-05-2024"},
+		{index: "logs-prod-latest", expected: "logs-prod-latest"},
+		{index: "archived-logs-prod-2024.05", expected: "archived-logs-prod-2024.05"},
+		{index: "metrics-prod-cpu", expected: "metrics-staging-cpu"},
+		{index: "traces-prod", expected: "traces-staging"},
+		{index: "users", expected: "users"},
+	} {
+		if renamed := renames.apply(c.index); renamed != c.expected {
+			t.Errorf("expecting index '%s' renamed to '%s', got '%s'", c.index, c.expected, renamed)
+		}
+	}
+
+	for _, rule := range []string{"logs-prod", "logs-(=logs"} {
+		if _, err := parseRenameRules([]string{rule}); err == nil {
+			t.Errorf("expecting rule '%s' to be invalid", rule)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// maxErrorReasons is the number of error reasons kept per index.
+const maxErrorReasons = 10
+
+// DefaultFailOnErrors fails the sync on any failed document.
+const DefaultFailOnErrors = 0.0
+
+var (
+	// ErrTotalFailure is returned by Sync when documents failed to be written and none is written.
+	ErrTotalFailure = errors.New("every document failed to be written")
+	// ErrPartialFailure is returned by Sync when more than the fail on errors percentage of the
+	// read documents failed to be written.
+	ErrPartialFailure = errors.New("documents failed to be written")
+)
+
+// SyncResult is the outcome of a sync, per index.
+type SyncResult struct {
+	Indices  []IndexResult
+	Duration time.Duration
+}
+
+// IndexResult counts the documents of an index, Total is the number of documents to sync counted
+// before reading, Skipped are documents kept on the destination according to the conflict policy,
+// and Bytes is the size of the written documents. Deleted and DeleteFailed are the destination
+// documents deleted, or failed to be deleted, when mirroring deletes. Errors are the first error
+// reasons of failed documents.
+type IndexResult struct {
+	Index        string
+	Destination  string
+	Total        int64
+	Read         int64
+	Written      int64
+	Skipped      int64
+	Failed       int64
+	Deleted      int64
+	DeleteFailed int64
+	Bytes        int64
+	Duration     time.Duration
+	Errors       []string
+}
+
+// Total returns the sum of every index counts.
+func (r SyncResult) Total() IndexResult {
+	total := IndexResult{Duration: r.Duration}
+	for _, i := range r.Indices {
+		total.Total += i.Total
+		total.Read += i.Read
+		total.Written += i.Written
+		total.Skipped += i.Skipped
+		total.Failed += i.Failed
+		total.Deleted += i.Deleted
+		total.DeleteFailed += i.DeleteFailed
+		total.Bytes += i.Bytes
+		total.Errors = append(total.Errors, i.Errors...)
+	}
+
+	return total
+}
+
+// Check returns ErrTotalFailure if no document is written nor deleted although documents failed,
+// or ErrPartialFailure if more than the threshold percentage of the read and deleted documents
+// failed. Failed deletes count as failed documents.
+func (r SyncResult) Check(threshold float64) error {
+	total := r.Total()
+	failed := total.Failed + total.DeleteFailed
+	if failed == 0 {
+		return nil
+	}
+
+	if total.Written+total.Skipped+total.Deleted == 0 {
+		return ErrTotalFailure
+	}
+
+	if float64(failed)*100/float64(total.Read+total.Deleted+total.DeleteFailed) > threshold {
+		return ErrPartialFailure
+	}
+
+	return nil
+}
+
+// WriteTable writes the counts of every index as a table, followed by the error reasons.
+func (r SyncResult) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "INDEX\tDESTINATION\tREAD\tWRITTEN\tSKIPPED\tFAILED\tDELETED\tDELETE FAILED\tSIZE\tDURATION")
+	for _, i := range r.Indices {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n", i.Index, i.Destination, i.Read, i.Written, i.Skipped, i.Failed, i.Deleted, i.DeleteFailed, formatBytes(i.Bytes), i.Duration.Round(time.Millisecond))
+	}
+
+	total := r.Total()
+	fmt.Fprintf(tw, "total\t\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n", total.Read, total.Written, total.Skipped, total.Failed, total.Deleted, total.DeleteFailed, formatBytes(total.Bytes), total.Duration.Round(time.Millisecond))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, i := range r.Indices {
+		for _, reason := range i.Errors {
+			if _, err := fmt.Fprintf(w, "index '%s': %s\n", i.Index, reason); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// indexCounter counts the documents of an index, it's safe for concurrent use. Counters are first
+// to be 64-bit aligned for atomic operations.
+type indexCounter struct {
+	total        int64
+	read         int64
+	written      int64
+	skipped      int64
+	failed       int64
+	deleted      int64
+	deleteFailed int64
+	bytes        int64
+	duration     int64
+
+	destination string
+
+	mu     sync.Mutex
+	errors []string
+}
+
+func (i *indexCounter) fail(reason string) {
+	atomic.AddInt64(&i.failed, 1)
+	i.addError(reason)
+}
+
+func (i *indexCounter) failDelete(reason string) {
+	atomic.AddInt64(&i.deleteFailed, 1)
+	i.addError(reason)
+}
+
+// addError keeps the error reason if there are less than maxErrorReasons.
+func (i *indexCounter) addError(reason string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if len(i.errors) < maxErrorReasons {
+		i.errors = append(i.errors, reason)
+	}
+}
+
+// syncCounter counts the documents of every synced index, keyed by source index.
+type syncCounter struct {
+	start   time.Time
+	indices map[string]*indexCounter
+}
+
+func newSyncCounter(indices map[string]string) *syncCounter {
+	s := &syncCounter{start: time.Now(), indices: make(map[string]*indexCounter, len(indices))}
+	for index, dest := range indices {
+		s.indices[index] = &indexCounter{destination: dest}
+	}
+
+	return s
+}
+
+// index returns the counter of the source index, documents of unknown indices aren't counted.
+func (s *syncCounter) index(index string) *indexCounter {
+	if s == nil || s.indices[index] == nil {
+		return &indexCounter{}
+	}
+
+	return s.indices[index]
+}
+
+func (s *syncCounter) result() SyncResult {
+	if s == nil {
+		return SyncResult{}
+	}
+
+	result := SyncResult{Duration: time.Since(s.start)}
+	for index, i := range s.indices {
+		i.mu.Lock()
+		result.Indices = append(result.Indices, IndexResult{
+			Index:        index,
+			Destination:  i.destination,
+			Total:        atomic.LoadInt64(&i.total),
+			Read:         atomic.LoadInt64(&i.read),
+			Written:      atomic.LoadInt64(&i.written),
+			Skipped:      atomic.LoadInt64(&i.skipped),
+			Failed:       atomic.LoadInt64(&i.failed),
+			Deleted:      atomic.LoadInt64(&i.deleted),
+			DeleteFailed: atomic.LoadInt64(&i.deleteFailed),
+			Bytes:        atomic.LoadInt64(&i.bytes),
+			Duration:     time.Duration(atomic.LoadInt64(&i.duration)),
+			Errors:       append([]string{}, i.errors...),
+		})
+		i.mu.Unlock()
+	}
+
+	sort.Slice(result.Indices, func(i, j int) bool { return result.Indices[i].Index < result.Indices[j].Index })
+	return result
+}
@@ -0,0 +1,113 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+func TestSyncResultCheck(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		indices   []IndexResult
+		threshold float64
+		err       error
+	}{
+		{name: "no failure", indices: []IndexResult{{Read: 10, Written: 10}}},
+		{name: "empty"},
+		{name: "any failure", indices: []IndexResult{{Read: 10, Written: 9, Failed: 1}}, err: ErrPartialFailure},
+		{name: "below threshold", indices: []IndexResult{{Read: 10, Written: 9, Failed: 1}}, threshold: 10},
+		{name: "above threshold", indices: []IndexResult{{Read: 10, Written: 5, Skipped: 3, Failed: 2}}, threshold: 10, err: ErrPartialFailure},
+		{name: "total failure", indices: []IndexResult{{Read: 10, Failed: 10}}, threshold: 100, err: ErrTotalFailure},
+		{name: "total failure across indices", indices: []IndexResult{{Read: 5, Failed: 5}, {Read: 0}}, err: ErrTotalFailure},
+		{name: "partial failure across indices", indices: []IndexResult{{Read: 5, Failed: 5}, {Read: 5, Written: 5}}, threshold: 40, err: ErrPartialFailure},
+		{name: "deleted", indices: []IndexResult{{Read: 10, Written: 10, Deleted: 5}}},
+		{name: "failed deletes", indices: []IndexResult{{Read: 10, Written: 10, DeleteFailed: 5}}, threshold: 10, err: ErrPartialFailure},
+		{name: "failed deletes below threshold", indices: []IndexResult{{Read: 10, Written: 10, Deleted: 9, DeleteFailed: 1}}, threshold: 10},
+		{name: "every delete failed", indices: []IndexResult{{DeleteFailed: 5}}, err: ErrTotalFailure},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if err := (SyncResult{Indices: c.indices}).Check(c.threshold); err != c.err {
+				t.Errorf("expecting error %v, got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestOnReadCounts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/_bulk" {
+			w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+			return
+		}
+
+		b, _ := io.ReadAll(r.Body)
+		var items []string
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		for i := 0; i < len(lines); i += 2 {
+			var action struct {
+				Create struct {
+					ID string `json:"_id"`
+				} `json:"create"`
+			}
+			if err := json.Unmarshal([]byte(lines[i]), &action); err != nil {
+				t.Error(err)
+			}
+
+			switch action.Create.ID {
+			case "conflict":
+				items = append(items, `{"create": {"_index": "logs", "_id": "conflict", "status": 409, "error": {"type": "version_conflict_engine_exception", "reason": "document already exists"}}}`)
+			case "invalid-1", "invalid-2":
+				items = append(items, fmt.Sprintf(`{"create": {"_index": "logs", "_id": "%s", "status": 400, "error": {"type": "mapper_parsing_exception", "reason": "failed to parse field [status]"}}}`, action.Create.ID))
+			default:
+				items = append(items, fmt.Sprintf(`{"create": {"_index": "logs", "_id": "%s", "status": 201, "result": "created"}}`, action.Create.ID))
+			}
+		}
+
+		fmt.Fprintf(w, `{"errors": true, "items": [%s]}`, strings.Join(items, ","))
+	}))
+	defer srv.Close()
+
+	toClient, err := newReadWriteClient(readWriteClientConfig{host: srv.URL, onConflict: OnConflictCreate})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cl := &Client{toClient: toClient, counter: newSyncCounter(map[string]string{"logs": "logs"}), logger: defaultLogger()}
+	onRead := cl.onRead(context.Background(), nil, "")
+	for _, id := range []string{"1", "2", "conflict", "invalid-1", "invalid-2"} {
+		onRead(util.Document{DocumentMetadata: util.DocumentMetadata{Index: "logs", ID: id}, Source: json.RawMessage(`{"a":1}`)})
+	}
+
+	if err := toClient.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	toClient.Wait()
+	result := cl.counter.result()
+	if len(result.Indices) != 1 {
+		t.Fatalf("expecting 1 index, got %+v", result.Indices)
+	}
+
+	i := result.Indices[0]
+	if i.Read != 5 || i.Written != 2 || i.Skipped != 1 || i.Failed != 2 || i.Bytes != 14 {
+		t.Errorf("expecting 5 read, 2 written of 14 bytes, 1 skipped and 2 failed documents, got %+v", i)
+	}
+
+	if len(i.Errors) != 2 || !strings.Contains(i.Errors[0], "failed to parse field [status]") {
+		t.Errorf("expecting error reasons, got %v", i.Errors)
+	}
+
+	if err := result.Check(0); err != ErrPartialFailure {
+		t.Errorf("expecting partial failure, got %v", err)
+	}
+}
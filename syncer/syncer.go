@@ -1,11 +1,16 @@
 package syncer
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"sort"
+	"sync/atomic"
 	"time"
 
+	"golang.org/x/sync/errgroup"
+
 	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
 )
 
@@ -13,6 +18,22 @@ const (
 	DefaultSince = 30 * 24 * time.Hour // 30 days
 	DefaultLimit = 0
 	DefaultIndex = ""
+
+	// DefaultTimeField is empty, meaning the time field is detected from the index mappings.
+	DefaultTimeField = ""
+
+	// DefaultStateFile is the state file where checkpoints of every synced index are persisted when
+	// resuming without state file configured.
+	DefaultStateFile = "elastic-syncer-state.json"
+
+	DefaultFollowInterval = time.Minute
+
+	DefaultSlices   = 1
+	DefaultPageSize = 1000
+
+	DefaultReadMode = ReadModeAuto
+
+	DefaultOnConflict = OnConflictOverwrite
 )
 
 type Config struct {
@@ -20,6 +41,107 @@ type Config struct {
 	Limit int
 	Index string
 
+	// TimeField is the `date` or `date_nanos` field used to sort and filter documents, nested
+	// fields are specified with dotted path. If it's empty, it's detected from the index mappings,
+	// otherwise syncing an index without this date field fails rather than copying it unfiltered.
+	TimeField string
+
+	// StateFile is where the checkpoint of every synced index is persisted, checkpoints are disabled
+	// if it's empty and Resume isn't set. If Resume is set, reading continues from the checkpoints
+	// persisted in StateFile, or DefaultStateFile if it's empty.
+	StateFile string
+	Resume    bool
+
+	// Follow keeps polling indices for new documents every FollowInterval after the initial sync,
+	// until the context is cancelled.
+	Follow         bool
+	FollowInterval time.Duration
+
+	// Slices is the number of slices each point-in-time is split into and read concurrently, and
+	// PageSize is the number of documents read per search request.
+	Slices   int
+	PageSize int
+
+	// Query is a query DSL selecting the documents to sync, either a query or a search body with
+	// `query`, and QueryString is a lucene query string selecting the documents to sync.
+	Query       json.RawMessage
+	QueryString string
+
+	// SourceIncludes and SourceExcludes are the `_source` fields included and excluded from the
+	// synced documents, wildcards are supported. If DropExcludedMappings is set, the excluded fields
+	// are dropped from the mappings of the created destination indices as well.
+	SourceIncludes       []string
+	SourceExcludes       []string
+	DropExcludedMappings bool
+
+	// SettingsAllow and SettingsDeny are dotted index setting keys copied to, or not copied to,
+	// created destination indices, in addition to esutil.DefaultSettingsDeny. The most specific
+	// key matching a setting wins, e.g. allowing `index.routing.allocation.require` keeps it even
+	// though `index.routing.allocation` is denied by default.
+	SettingsAllow []string
+	SettingsDeny  []string
+
+	// ReconcileMappings adds the fields missing on existing destination indices mappings before
+	// syncing, conflicting field types abort the sync either way.
+	ReconcileMappings bool
+
+	// Rename are rules renaming source indices to destination indices, formatted as
+	// `pattern=replacement` where pattern is a regular expression matching the whole index name,
+	// e.g. `logs-prod-(.*)=logs-staging-$1`, or `prefix:old=new` and `suffix:old=new`. The first
+	// matching rule applies.
+	Rename []string
+
+	// TransformFile is a JSON file configuring the transforms applied to documents before they're
+	// written, see TransformFile. Transformers are applied after the transform file transforms.
+	TransformFile string
+	Transformers  []Transformer
+
+	// ExternalVersion indexes documents with their source `_version` as external version, so a
+	// destination document is only overwritten by a newer source document, and re-runs never
+	// overwrite newer destination data.
+	ExternalVersion bool
+
+	// OnConflict is the policy for documents already existing on the destination, one of
+	// OnConflictCreate, OnConflictOverwrite, OnConflictNewerWins or OnConflictMerge. Defaults to
+	// OnConflictOverwrite.
+	OnConflict string
+
+	// MirrorDeletes deletes destination documents within the sync window which don't exist on the
+	// source, after the indices are synced. IDs are compared as is, so it can't be used with
+	// transforms computing document IDs. If more than MirrorDeletesThreshold percent of the
+	// destination documents would be deleted, the sync is aborted instead, it defaults to
+	// DefaultMirrorDeletesThreshold if nil.
+	MirrorDeletes          bool
+	MirrorDeletesThreshold *float64
+
+	// DeadLetterFile is the NDJSON file where documents failed to be written are appended, with
+	// the error, so they can be replayed. Set to empty to disable it.
+	DeadLetterFile string
+
+	// FailOnErrors is the percentage of read documents which can fail to be written, or of mirrored
+	// deletes which can fail, before Sync returns ErrPartialFailure. Sync returns ErrTotalFailure if
+	// every document failed either way.
+	FailOnErrors float64
+
+	// ProgressInterval is the interval between progress log entries of every index, if Terminal is
+	// set the progress is a single line updated every second instead. Set to 0 to disable it.
+	ProgressInterval time.Duration
+
+	// Terminal is where the progress line is drawn, Logger must write to it as well so log entries
+	// don't break the progress line. Progress is logged if it's nil.
+	Terminal *Terminal
+
+	// Logger writes the sync logs, documents read and written are logged at debug level. Defaults
+	// to text logs of at least info level on stderr.
+	Logger Logger
+
+	// Metrics collects the sync metrics if set.
+	Metrics *Metrics
+
+	// ReadMode forces how documents are read from the source, one of ReadModeAuto, ReadModePIT,
+	// ReadModeScroll or ReadModePaginate. Defaults to ReadModeAuto.
+	ReadMode string
+
 	FromHost         string
 	FromUsername     string
 	FromPassword     string
@@ -38,18 +160,62 @@ type Client struct {
 	toClient   *readWriteClient
 	index      string
 
-	from  time.Time
-	to    time.Time
-	limit int
+	from      time.Time
+	to        time.Time
+	limit     int
+	timeField string
+
+	checkpoints *checkpointStore
+	resume      bool
+
+	follow         bool
+	followInterval time.Duration
+
+	slices   int
+	pageSize int
+
+	filter      json.RawMessage
+	queryString string
+
+	sourceIncludes       []string
+	sourceExcludes       []string
+	dropExcludedMappings bool
+
+	settingsAllow []string
+	settingsDeny  []string
+
+	reconcileMappings bool
+
+	renames renameRules
+
+	transformer TransformChain
+
+	mirrorDeletes          bool
+	mirrorDeletesThreshold float64
+
+	deadLetters *deadLetterWriter
+
+	failOnErrors float64
+	counter      *syncCounter
+
+	progressInterval time.Duration
+	terminal         *Terminal
+	logger           Logger
+
+	metrics *Metrics
 }
 
 func New(cfg Config) (*Client, error) {
+	cfg.Logger = loggerOrDefault(cfg.Logger)
 	fromClient, err := newReadClient(readClientConfig{
 		address:      cfg.FromHost,
 		username:     cfg.FromUsername,
 		password:     cfg.FromPassword,
 		logRequests:  cfg.LogFromRequests,
 		logResponses: cfg.LogFromResponses,
+		readMode:     cfg.ReadMode,
+		metrics:      cfg.Metrics,
+		logger:       cfg.Logger.With("cluster", "source"),
 	})
 
 	if err != nil {
@@ -62,46 +228,231 @@ func New(cfg Config) (*Client, error) {
 		password:     cfg.ToPassword,
 		logRequests:  cfg.LogToRequests,
 		logResponses: cfg.LogToResponses,
+
+		onConflict:      cfg.OnConflict,
+		externalVersion: cfg.ExternalVersion,
+
+		metrics: cfg.Metrics,
+		logger:  cfg.Logger.With("cluster", "destination"),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create to client, %s", err.Error())
 	}
 
+	if cfg.Resume && cfg.StateFile == "" {
+		cfg.StateFile = DefaultStateFile
+	}
+
+	var checkpoints *checkpointStore
+	if cfg.StateFile != "" {
+		checkpoints, err = loadCheckpointStore(cfg.StateFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load state file, %s", err.Error())
+		}
+	}
+
+	filter, err := parseQuery(cfg.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	renames, err := parseRenameRules(cfg.Rename)
+	if err != nil {
+		return nil, err
+	}
+
+	var transformer TransformChain
+	if cfg.TransformFile != "" {
+		t, err := LoadTransformFile(cfg.TransformFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load transform file, %s", err.Error())
+		}
+
+		transformer = append(transformer, t)
+	}
+
+	transformer = append(transformer, cfg.Transformers...)
+
+	if cfg.MirrorDeletes && cfg.Limit > 0 {
+		return nil, ErrMirrorDeletesLimit
+	}
+
+	if cfg.MirrorDeletes && transformer.ComputesID() {
+		return nil, ErrMirrorDeletesID
+	}
+
+	mirrorDeletesThreshold := DefaultMirrorDeletesThreshold
+	if cfg.MirrorDeletesThreshold != nil {
+		mirrorDeletesThreshold = *cfg.MirrorDeletesThreshold
+	}
+
+	var deadLetters *deadLetterWriter
+	if cfg.DeadLetterFile != "" {
+		deadLetters = newDeadLetterWriter(cfg.DeadLetterFile)
+	}
+
+	if cfg.FollowInterval == 0 {
+		cfg.FollowInterval = DefaultFollowInterval
+	}
+
 	now := time.Now().UTC()
 	from, to := now.Add(-cfg.Since), now
 
 	cl := &Client{
-		fromClient: fromClient,
-		toClient:   toClient,
-		index:      cfg.Index,
-		from:       from,
-		to:         to,
-		limit:      cfg.Limit,
+		fromClient:  fromClient,
+		toClient:    toClient,
+		index:       cfg.Index,
+		from:        from,
+		to:          to,
+		limit:       cfg.Limit,
+		timeField:   cfg.TimeField,
+		checkpoints: checkpoints,
+		resume:      cfg.Resume,
+
+		follow:         cfg.Follow,
+		followInterval: cfg.FollowInterval,
+
+		slices:   cfg.Slices,
+		pageSize: cfg.PageSize,
+
+		filter:      filter,
+		queryString: cfg.QueryString,
+
+		sourceIncludes:       cfg.SourceIncludes,
+		sourceExcludes:       cfg.SourceExcludes,
+		dropExcludedMappings: cfg.DropExcludedMappings,
+
+		settingsAllow: cfg.SettingsAllow,
+		settingsDeny:  append(append([]string{}, util.DefaultSettingsDeny...), cfg.SettingsDeny...),
+
+		reconcileMappings: cfg.ReconcileMappings,
+
+		renames:     renames,
+		transformer: transformer,
+
+		mirrorDeletes:          cfg.MirrorDeletes,
+		mirrorDeletesThreshold: mirrorDeletesThreshold,
+
+		deadLetters: deadLetters,
+
+		failOnErrors: cfg.FailOnErrors,
+
+		progressInterval: cfg.ProgressInterval,
+		terminal:         cfg.Terminal,
+		logger:           cfg.Logger,
+
+		metrics: cfg.Metrics,
 	}
 
 	return cl, nil
 }
 
-func (c *Client) Sync(ctx context.Context) error {
-	log.Printf("syncing from '%s' to '%s'\n", c.from.Format(time.RFC3339), c.to.Format(time.RFC3339))
+// parseQuery validates a user supplied query DSL, unwrapping the query of a search body.
+func parseQuery(b json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, nil
+	}
+
+	var query map[string]json.RawMessage
+	if err := json.Unmarshal(b, &query); err != nil {
+		return nil, fmt.Errorf("query must be a JSON object, %s", err.Error())
+	}
+
+	if q, ok := query["query"]; ok {
+		return q, nil
+	}
+
+	return b, nil
+}
+
+// Sync copies the documents of every index, and returns the counts of every index. Documents
+// failing to be written don't abort the sync, but ErrTotalFailure or ErrPartialFailure is returned
+// once every index is synced, according to FailOnErrors.
+func (c *Client) Sync(ctx context.Context) (SyncResult, error) {
+	err := c.sync(ctx)
+	result := c.counter.result()
+	if err != nil {
+		return result, err
+	}
+
+	return result, result.Check(c.failOnErrors)
+}
 
+func (c *Client) sync(ctx context.Context) error {
+	c.logger.Infof("syncing from '%s' to '%s'", c.from.Format(time.RFC3339), c.to.Format(time.RFC3339))
+
+	flushed := make(chan struct{})
 	go func() {
+		defer close(flushed)
 		<-ctx.Done()
-		log.Println("context cancelled")
+		c.logger.Infof("context cancelled")
 		flushContext, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 		if err := c.toClient.Flush(flushContext); err != nil {
-			log.Printf("failed to flush: %s\n", err)
+			c.logger.Errorf("failed to flush: %s", err)
 		}
 	}()
 
-	log.Printf("reading index settings for '%s'\n", c.index)
+	if c.checkpoints != nil {
+		stop := c.checkpoints.autosave(checkpointSaveInterval, c.logger)
+		defer func() {
+			stop()
+			if ctx.Err() != nil {
+				<-flushed
+			}
+
+			if err := c.checkpoints.save(); err != nil {
+				c.logger.Errorf("failed to save state file, %s", err.Error())
+			}
+		}()
+	}
+
+	c.logger.Infof("reading index settings for '%s'", c.index)
 	settings, err := c.fromClient.ReadIndexSettings(ctx, c.index)
 	if err != nil {
 		return fmt.Errorf("can not get index settings for '%s', %s", c.index, err.Error())
 	}
 
-	log.Printf("found %d indexes \n", len(settings))
+	if len(c.filter) > 0 || c.queryString != "" {
+		c.logger.Infof("validating query on '%s'", c.index)
+		req := readAllRequest{index: c.index, filter: c.filter, queryString: c.queryString}
+		if err := c.fromClient.ValidateQuery(ctx, req); err != nil {
+			return fmt.Errorf("can not validate query, %s", err.Error())
+		}
+	}
+
+	c.logger.Infof("found %d indexes", len(settings))
+
+	// time fields are resolved before any index is created or read, so an index missing the
+	// configured time field aborts the sync without leaving anything behind.
+	timeFields := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		timeField, err := resolveTimeField(setting.Setting.Mappings, c.timeField)
+		if err != nil {
+			return fmt.Errorf("can not sync index '%s', %s", setting.Index, err.Error())
+		}
+
+		timeFields[setting.Index] = timeField
+	}
+
+	destinations := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		destinations[setting.Index] = c.renames.apply(setting.Index)
+		c.logger.With("index", setting.Index).Infof("index is synced to '%s'", destinations[setting.Index])
+		if err := c.transformer.Prepare(setting); err != nil {
+			return fmt.Errorf("can not prepare transforms for index '%s', %s", setting.Index, err.Error())
+		}
+	}
+
+	c.counter = newSyncCounter(destinations)
+	if c.progressInterval > 0 {
+		stop := c.reportProgress(c.progressInterval)
+		defer stop()
+	}
+
+	defer c.logMaskReport()
+	defer c.closeDeadLetters()
+
 	for _, setting := range settings {
 		select {
 		case <-ctx.Done():
@@ -109,52 +460,329 @@ func (c *Client) Sync(ctx context.Context) error {
 		default:
 		}
 
-		log.Printf("checking index '%s' on destination elasticsearch\n", setting.Index)
-		exist, err := c.toClient.IndexExist(ctx, setting.Index)
+		dest := c.destinationSetting(setting)
+		logger := c.logger.With("index", dest.Index)
+		logger.Infof("checking index on destination elasticsearch")
+		exist, err := c.toClient.IndexExist(ctx, dest.Index)
 		if err != nil {
-			return fmt.Errorf("can not check index exist for '%s', %s", setting.Index, err.Error())
+			return fmt.Errorf("can not check index exist for '%s', %s", dest.Index, err.Error())
 		}
 
 		if exist {
-			log.Printf("index '%s' exist on destination elasticsearch\n", setting.Index)
+			logger.Infof("index exist on destination elasticsearch")
+			if err := c.checkMappings(ctx, dest); err != nil {
+				return err
+			}
+
 			continue
 		}
 
-		log.Printf("index '%s' doesn't exist on destination elasticsearch, creating...\n", setting.Index)
-		if err := c.toClient.CreateIndex(ctx, setting); err != nil {
-			return fmt.Errorf("failed to create index '%s', %s", setting.Index, err.Error())
+		logger.Infof("index doesn't exist on destination elasticsearch, creating...")
+		if err := c.toClient.CreateIndex(ctx, dest); err != nil {
+			return fmt.Errorf("failed to create index '%s', %s", dest.Index, err.Error())
+		}
+
+		logger.Infof("index created on destination elasticsearch")
+	}
+
+	g := new(errgroup.Group)
+	watermarks := make(map[string]time.Time, len(settings))
+	for _, setting := range settings {
+		req := c.request(setting.Index)
+		timeField := timeFields[setting.Index]
+		tracker, skip := c.checkpoint(ctx, &req, setting)
+		watermarks[setting.Index] = req.to
+		if skip {
+			c.logger.With("index", setting.Index).Infof("index is completely synced according to state file, skipping")
+			continue
+		}
+
+		setting := setting
+		g.Go(func() error {
+			start := time.Now()
+			defer func() {
+				atomic.StoreInt64(&c.counter.index(setting.Index).duration, int64(time.Since(start)))
+			}()
+
+			var resumed int64
+			if tracker != nil {
+				resumed = tracker.written()
+			}
+
+			c.countTotal(ctx, req, setting, resumed)
+
+			if err := c.fromClient.ReadIndex(ctx, req, setting, c.onRead(ctx, tracker, timeField)); err != nil {
+				return err
+			}
+
+			if tracker != nil {
+				tracker.finish()
+			}
+
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return fmt.Errorf("can not read, %s", err.Error())
+	}
+
+	c.fromClient.Wait()
+	if c.mirrorDeletes {
+		for _, setting := range settings {
+			if err := c.deleteMissing(ctx, setting); err != nil {
+				return fmt.Errorf("can not mirror deletes of index '%s', %s", setting.Index, err.Error())
+			}
 		}
+	}
+
+	if c.follow {
+		c.logger.Infof("following indices every %s", c.followInterval)
+		c.followIndices(ctx, settings, watermarks)
+		c.fromClient.Wait()
+		<-flushed
+	}
+
+	c.toClient.Wait()
+	return nil
+}
+
+// logMaskReport logs the number of masked values per field.
+func (c *Client) logMaskReport() {
+	report := c.transformer.MaskReport()
+	fields := make([]string, 0, len(report))
+	for field := range report {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+	for _, field := range fields {
+		c.logger.With("field", field).Infof("masked %d values", report[field])
+	}
+}
+
+// closeDeadLetters closes the dead-letter file, and logs the number of failed documents written to it.
+func (c *Client) closeDeadLetters() {
+	if c.deadLetters == nil {
+		return
+	}
+
+	count, err := c.deadLetters.close()
+	if err != nil {
+		c.logger.Errorf("failed to close dead-letter file, %s", err.Error())
+	}
+
+	if count > 0 {
+		c.logger.Warnf("%d failed documents are written to dead-letter file '%s'", count, c.deadLetters.path)
+	}
+}
+
+// destinationSetting returns the index setting used to create the destination index, renamed,
+// without the non-creatable settings, and without the excluded fields mappings if they're dropped.
+func (c *Client) destinationSetting(setting util.IndexSetting) util.IndexSetting {
+	setting.Index = c.renames.apply(setting.Index)
+	setting.Setting.Settings = setting.Setting.Settings.Filter(c.settingsAllow, c.settingsDeny)
+	if c.dropExcludedMappings && len(c.sourceExcludes) > 0 {
+		setting.Setting.Mappings = setting.Setting.Mappings.Without(c.sourceExcludes...)
+	}
+
+	return setting
+}
+
+// checkMappings compares the mappings of the existing destination index with the mappings of the
+// destination setting. Fields added on the source are put to the destination mappings if
+// reconciling mappings, and conflicting fields are returned as error.
+func (c *Client) checkMappings(ctx context.Context, setting util.IndexSetting) error {
+	dest, err := c.toClient.GetMapping(ctx, setting.Index)
+	if err != nil {
+		return fmt.Errorf("can not get mappings of index '%s' on destination elasticsearch, %s", setting.Index, err.Error())
+	}
 
-		log.Printf("index '%s' created on destination elasticsearch\n", setting.Index)
+	source := setting.Setting.Mappings
+	diff := util.DiffMappings(source, dest)
+	if diff.Equal() {
+		return nil
 	}
 
-	req := readAllRequest{
-		from:  c.from,
-		to:    c.to,
-		limit: c.limit,
-		index: c.index,
+	logger := c.logger.With("index", setting.Index)
+	logger.Warnf("mappings differ between source and destination elasticsearch:\n%s", diff)
+	if len(diff.Conflicts) > 0 {
+		return fmt.Errorf("mappings of index '%s' have %d conflicting fields:\n%s", setting.Index, len(diff.Conflicts), diff)
 	}
 
-	err = c.fromClient.ReadAll(ctx, req, func(doc util.Document) {
-		log.Printf("found document '%s/%s'\n", doc.Index, doc.ID)
+	if len(diff.Added) == 0 {
+		return nil
+	}
+
+	if !c.reconcileMappings {
+		logger.Warnf("%d fields aren't mapped on destination elasticsearch, use reconcile mappings to add them", len(diff.Added))
+		return nil
+	}
+
+	logger.Infof("adding %d fields to mappings on destination elasticsearch", len(diff.Added))
+	if err := c.toClient.PutMapping(ctx, setting.Index, source.Subset(diff.Added...)); err != nil {
+		return fmt.Errorf("can not add fields to mappings of index '%s', %s", setting.Index, err.Error())
+	}
+
+	return nil
+}
+
+// request returns the request reading the index within the sync time window.
+func (c *Client) request(index string) readAllRequest {
+	return readAllRequest{
+		from:        c.from,
+		to:          c.to,
+		limit:       c.limit,
+		index:       index,
+		timeField:   c.timeField,
+		filter:      c.filter,
+		queryString: c.queryString,
+		size:        c.pageSize,
+		slices:      c.slices,
+
+		sourceIncludes: c.sourceIncludes,
+		sourceExcludes: c.sourceExcludes,
+	}
+}
+
+// checkpoint sets up checkpoint tracking for the index if checkpoints are enabled and the index is
+// read with point-in-time. When resuming, the request continues from the persisted checkpoint, and
+// skip is true if the index is already completely synced.
+func (c *Client) checkpoint(ctx context.Context, req *readAllRequest, setting util.IndexSetting) (tracker *checkpointTracker, skip bool) {
+	if c.checkpoints == nil {
+		return nil, false
+	}
+
+	// an error resolving the time field or getting the read mode is returned when reading the index.
+	timeField, err := resolveTimeField(setting.Setting.Mappings, req.timeField)
+	if err != nil {
+		return nil, false
+	}
+
+	if mode, err := c.fromClient.ReadMode(ctx, timeField); err != nil || mode != ReadModePIT {
+		return nil, false
+	}
+
+	slices := req.slices
+	if slices < 1 {
+		slices = 1
+	}
+
+	cp := Checkpoint{
+		TimeField: timeField,
+		From:      req.from,
+		To:        req.to,
+		Slices:    slices,
+	}
+
+	if prev, ok := c.checkpoints.get(req.index); ok && c.resume {
+		switch {
+		case prev.TimeField != timeField:
+			c.logger.With("index", req.index).Warnf("checkpoint is on time field '%s' instead of '%s', starting over", prev.TimeField, timeField)
+		case !prev.Complete && prev.Slices != slices:
+			c.logger.With("index", req.index).Warnf("checkpoint is read in %d slices instead of %d, starting over", prev.Slices, slices)
+		case prev.Complete:
+			req.from, req.to = prev.From, prev.To
+			return nil, true
+		default:
+			cp = prev
+			req.from, req.to, req.after = prev.From, prev.To, prev.Sort
+		}
+	}
+
+	req.timeField = timeField
+	tracker = newCheckpointTracker(c.checkpoints, req.index, cp)
+	req.onQueue = tracker.queue
+	return tracker, false
+}
+
+func (c *Client) onRead(ctx context.Context, tracker *checkpointTracker, timeField string) func(doc util.Document) {
+	return func(doc util.Document) {
+		c.logger.Debugf("found document '%s/%s'", doc.Index, doc.ID)
+		counter := c.counter.index(doc.Index)
+		atomic.AddInt64(&counter.read, 1)
+		c.metrics.documentRead(doc.Index)
+
+		// transforms may change the document ID and index, the checkpoint tracks the source
+		// document ID and metrics the source index.
+		id, index := doc.ID, doc.Index
+		doc, err := c.transformer.Transform(doc)
+		if err != nil {
+			c.logger.With("index", index).Errorf("failed to transform document '%s', %s", id, err.Error())
+			counter.fail(fmt.Sprintf("failed to transform document '%s', %s", id, err.Error()))
+			c.metrics.documentFailed(index)
+			return
+		}
+
+		doc.Index = c.renames.apply(doc.Index)
+		source := doc.Source
 		if err := c.toClient.WriteDocument(
 			ctx,
 			doc,
-			func(doc util.DocumentMetadata) {
-				log.Printf("done writing document '%s/%s'\n", doc.Index, doc.ID)
+			timeField,
+			func(doc util.DocumentMetadata, result WriteResult) {
+				c.logger.Debugf("done writing document '%s/%s', %s", doc.Index, doc.ID, result)
+				c.metrics.documentWritten(index, result)
+				if result == WriteSkipped {
+					atomic.AddInt64(&counter.skipped, 1)
+				} else {
+					atomic.AddInt64(&counter.written, 1)
+					atomic.AddInt64(&counter.bytes, int64(len(source)))
+				}
+
+				if tracker != nil {
+					tracker.ack(id)
+				}
 			},
-			func(doc util.DocumentMetadata, err error) {
-				log.Printf("failed to write document '%s/%s', %s\n", doc.Index, doc.ID, err.Error())
+			func(_ util.DocumentMetadata, err error) {
+				counter.fail(fmt.Sprintf("failed to write document '%s', %s", id, err.Error()))
+				c.metrics.documentFailed(index)
+				c.onWriteError(doc, timeField, err)
 			},
 		); err != nil {
-			log.Printf("failed to write document '%s/%s', %s\n", doc.Index, doc.ID, err.Error())
+			counter.fail(fmt.Sprintf("failed to write document '%s', %s", id, err.Error()))
+			c.metrics.documentFailed(index)
+			c.onWriteError(doc, timeField, err)
 		}
-	})
+	}
+}
 
+// countTotal counts the documents of the index to sync, for progress reporting. The documents
+// already synced before resuming are not counted.
+func (c *Client) countTotal(ctx context.Context, req readAllRequest, setting util.IndexSetting, resumed int64) {
+	req.setDefaults()
+	timeField, err := resolveTimeField(setting.Setting.Mappings, req.timeField)
 	if err != nil {
-		return fmt.Errorf("can not read, %s", err.Error())
+		return
 	}
 
-	c.toClient.Wait()
-	return nil
+	req.timeField = timeField
+	total, err := c.fromClient.Count(ctx, setting.Index, req.query())
+	if err != nil {
+		c.logger.With("index", setting.Index).Warnf("can not count documents, %s", err.Error())
+		return
+	}
+
+	if total -= resumed; total < 0 {
+		total = 0
+	}
+
+	if req.limit > 0 && total > int64(req.limit) {
+		total = int64(req.limit)
+	}
+
+	atomic.StoreInt64(&c.counter.index(setting.Index).total, total)
+}
+
+// onWriteError logs the document failed to be written, and writes it to the dead-letter file.
+func (c *Client) onWriteError(doc util.Document, timeField string, err error) {
+	logger := c.logger.With("index", doc.Index)
+	logger.Errorf("failed to write document '%s', %s", doc.ID, err.Error())
+	if c.deadLetters == nil {
+		return
+	}
+
+	if err := c.deadLetters.write(newDeadLetter(doc, timeField, err, 1)); err != nil {
+		logger.Errorf("failed to write document '%s' to dead-letter file, %s", doc.ID, err.Error())
+	}
 }
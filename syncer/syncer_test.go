@@ -0,0 +1,179 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+func TestParseQuery(t *testing.T) {
+	for _, c := range []struct {
+		query    string
+		expected string
+		err      bool
+	}{
+		{query: "", expected: ""},
+		{query: `{"term": {"tenant": "foo"}}`, expected: `{"term": {"tenant": "foo"}}`},
+		{query: `{"query": {"term": {"tenant": "foo"}}}`, expected: `{"term": {"tenant": "foo"}}`},
+		{query: `{"query": {"match_all": {}}, "size": 10}`, expected: `{"match_all": {}}`},
+		{query: `tenant:foo`, err: true},
+	} {
+		q, err := parseQuery(json.RawMessage(c.query))
+		if (err != nil) != c.err {
+			t.Errorf("expecting error %t for '%s', got %v", c.err, c.query, err)
+		}
+
+		if string(q) != c.expected {
+			t.Errorf("expecting query '%s', got '%s'", c.expected, q)
+		}
+	}
+}
+
+func TestCheckMappings(t *testing.T) {
+	setting := util.IndexSetting{
+		Index: "logs",
+		Setting: util.SettingInner{
+			Mappings: util.Mappings{
+				Properties: map[string]util.MappingProperty{
+					"@timestamp": {Type: "date"},
+					"status":     {Type: "keyword"},
+				},
+			},
+		},
+	}
+
+	for _, c := range []struct {
+		name      string
+		dest      string
+		reconcile bool
+		put       string
+		err       bool
+	}{
+		{name: "equal", dest: `{"@timestamp": {"type": "date"}, "status": {"type": "keyword"}}`},
+		{name: "added", dest: `{"@timestamp": {"type": "date"}}`},
+		{name: "reconcile", dest: `{"@timestamp": {"type": "date"}}`, reconcile: true, put: `{"properties":{"status":{"type":"keyword"}}}`},
+		{name: "conflict", dest: `{"@timestamp": {"type": "date"}, "status": {"type": "long"}}`, reconcile: true, err: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var put string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Elastic-Product", "Elasticsearch")
+				w.Header().Set("Content-Type", "application/json")
+				if r.Method == http.MethodPut {
+					b, _ := io.ReadAll(r.Body)
+					put = strings.TrimSpace(string(b))
+					w.Write([]byte(`{"acknowledged": true}`))
+					return
+				}
+
+				w.Write([]byte(`{"logs": {"mappings": {"properties": ` + c.dest + `}}}`))
+			}))
+			defer srv.Close()
+
+			toClient, err := newReadWriteClient(readWriteClientConfig{host: srv.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cl := &Client{toClient: toClient, reconcileMappings: c.reconcile, logger: defaultLogger()}
+			err = cl.checkMappings(context.Background(), setting)
+			if c.err != (err != nil) {
+				t.Errorf("expecting error %t, got %v", c.err, err)
+			}
+
+			if put != c.put {
+				t.Errorf("expecting put mappings %s, got %s", c.put, put)
+			}
+		})
+	}
+}
+
+func TestNewStateFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+	}))
+	defer srv.Close()
+
+	for _, c := range []struct {
+		name      string
+		stateFile string
+		resume    bool
+		expected  string
+	}{
+		{name: "disabled"},
+		{name: "state file", stateFile: "state.json", expected: "state.json"},
+		{name: "resume", resume: true, expected: DefaultStateFile},
+		{name: "resume with state file", stateFile: "state.json", resume: true, expected: "state.json"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cl, err := New(Config{FromHost: srv.URL, ToHost: srv.URL, StateFile: c.stateFile, Resume: c.resume})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var path string
+			if cl.checkpoints != nil {
+				path = cl.checkpoints.path
+			}
+
+			if path != c.expected {
+				t.Errorf("expecting state file '%s', got '%s'", c.expected, path)
+			}
+		})
+	}
+}
+
+func TestSyncMissingTimeField(t *testing.T) {
+	var sourceRequests, destRequests []string
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+		case "/logs-*":
+			w.Write([]byte(`{
+				"logs-1": {"aliases": {}, "mappings": {"properties": {"created_at": {"type": "date"}}}, "settings": {}},
+				"logs-2": {"aliases": {}, "mappings": {"properties": {"name": {"type": "keyword"}}}, "settings": {}}
+			}`))
+		default:
+			sourceRequests = append(sourceRequests, r.Method+" "+r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer source.Close()
+
+	dest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/" {
+			w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+			return
+		}
+
+		destRequests = append(destRequests, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer dest.Close()
+
+	cl, err := New(Config{Index: "logs-*", TimeField: "created_at", FromHost: source.URL, ToHost: dest.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cl.Sync(context.Background()); err == nil || !strings.Contains(err.Error(), "logs-2") {
+		t.Errorf("expecting time field error of index 'logs-2', got %v", err)
+	}
+
+	if len(sourceRequests) > 0 || len(destRequests) > 0 {
+		t.Errorf("expecting nothing read nor created, got source requests %v and destination requests %v", sourceRequests, destRequests)
+	}
+}
@@ -0,0 +1,539 @@
+package syncer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+// Transformer changes a document read from the source before it's written to the destination.
+// A document failing to transform isn't written.
+type Transformer interface {
+	Transform(doc util.Document) (util.Document, error)
+}
+
+// TransformerFunc is a function used as Transformer.
+type TransformerFunc func(doc util.Document) (util.Document, error)
+
+func (f TransformerFunc) Transform(doc util.Document) (util.Document, error) {
+	return f(doc)
+}
+
+// TransformerFactory creates a transformer from its options in the transform file.
+type TransformerFactory func(options json.RawMessage) (Transformer, error)
+
+var (
+	transformsMu sync.RWMutex
+	transforms   = map[string]TransformerFactory{}
+)
+
+// RegisterTransform registers a transform type usable in transform files, replacing any transform
+// with the same name, built-in transforms included.
+func RegisterTransform(name string, factory TransformerFactory) {
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	transforms[name] = factory
+}
+
+func init() {
+	RegisterTransform("rename", sourceTransform(func() fieldTransform { return &renameFieldTransform{} }))
+	RegisterTransform("drop", sourceTransform(func() fieldTransform { return &dropFieldTransform{} }))
+	RegisterTransform("set", sourceTransform(func() fieldTransform { return &setFieldTransform{} }))
+	RegisterTransform("cast", sourceTransform(func() fieldTransform { return &castFieldTransform{} }))
+	RegisterTransform("id", newComputeIDTransform)
+}
+
+// TransformFile is the transform chain configuration, transforms are applied in order, e.g.
+//
+//	{"transforms": [
+//		{"type": "rename", "field": "host", "to": "host.name"},
+//		{"type": "drop", "fields": ["password", "debug.*"]},
+//		{"type": "set", "field": "env", "value": "staging"},
+//		{"type": "cast", "field": "status", "to": "int"},
+//		{"type": "id", "fields": ["tenant", "user.id"], "separator": "-"},
+//		{"type": "mask", "method": "hash", "fields": ["user.email"], "key_env": "MASK_KEY"}
+//	]}
+type TransformFile struct {
+	Transforms []json.RawMessage `json:"transforms"`
+}
+
+type transformType struct {
+	Type string `json:"type"`
+}
+
+// LoadTransformFile reads the transform chain configured in the JSON file at path.
+func LoadTransformFile(path string) (Transformer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseTransforms(b)
+}
+
+// ParseTransforms parses a transform chain configuration, see TransformFile.
+func ParseTransforms(b []byte) (Transformer, error) {
+	var file TransformFile
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("can not decode transforms, %s", err.Error())
+	}
+
+	chain := make(TransformChain, 0, len(file.Transforms))
+	for i, options := range file.Transforms {
+		var t transformType
+		if err := json.Unmarshal(options, &t); err != nil {
+			return nil, fmt.Errorf("can not decode transform #%d, %s", i, err.Error())
+		}
+
+		transformsMu.RLock()
+		factory, ok := transforms[t.Type]
+		transformsMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown transform #%d '%s', expecting one of '%s'", i, t.Type, strings.Join(registeredTransforms(), "', '"))
+		}
+
+		transformer, err := factory(options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transform #%d '%s', %s", i, t.Type, err.Error())
+		}
+
+		chain = append(chain, transformer)
+	}
+
+	return chain, nil
+}
+
+// TransformChain applies every transformer in order.
+type TransformChain []Transformer
+
+func (c TransformChain) Transform(doc util.Document) (util.Document, error) {
+	for _, t := range c {
+		var err error
+		doc, err = t.Transform(doc)
+		if err != nil {
+			return doc, err
+		}
+	}
+
+	return doc, nil
+}
+
+// Prepare prepares every transformer implementing IndexPreparer.
+func (c TransformChain) Prepare(setting util.IndexSetting) error {
+	for _, t := range c {
+		if p, ok := t.(IndexPreparer); ok {
+			if err := p.Prepare(setting); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// MaskReport sums the reports of every transformer implementing MaskReporter.
+func (c TransformChain) MaskReport() map[string]int64 {
+	report := map[string]int64{}
+	for _, t := range c {
+		if r, ok := t.(MaskReporter); ok {
+			for field, n := range r.MaskReport() {
+				report[field] += n
+			}
+		}
+	}
+
+	return report
+}
+
+// ComputesID returns true if any transformer implementing IDComputer computes document IDs.
+func (c TransformChain) ComputesID() bool {
+	for _, t := range c {
+		if i, ok := t.(IDComputer); ok && i.ComputesID() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fieldTransform changes the decoded document source.
+type fieldTransform interface {
+	validate() error
+	apply(source map[string]any) error
+}
+
+// sourceTransform returns a factory of transformers decoding the document source, applying the
+// field transform, and encoding the source back.
+func sourceTransform(newTransform func() fieldTransform) TransformerFactory {
+	return func(options json.RawMessage) (Transformer, error) {
+		t := newTransform()
+		if err := json.Unmarshal(options, t); err != nil {
+			return nil, err
+		}
+
+		if err := t.validate(); err != nil {
+			return nil, err
+		}
+
+		return TransformerFunc(func(doc util.Document) (util.Document, error) {
+			source, err := decodeSource(doc.Source)
+			if err != nil {
+				return doc, err
+			}
+
+			if err := t.apply(source); err != nil {
+				return doc, err
+			}
+
+			doc.Source, err = json.Marshal(source)
+			return doc, err
+		}), nil
+	}
+}
+
+// decodeSource decodes the document source with numbers kept as json.Number, so they're encoded
+// back without losing precision.
+func decodeSource(b json.RawMessage) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var source map[string]any
+	if err := dec.Decode(&source); err != nil {
+		return nil, fmt.Errorf("can not decode document source, %s", err.Error())
+	}
+
+	if source == nil {
+		source = map[string]any{}
+	}
+
+	return source, nil
+}
+
+// lookupField returns the object holding the dotted path field and its key, the field is either
+// nested objects, or a key containing dots.
+func lookupField(source map[string]any, field string) (parent map[string]any, key string, ok bool) {
+	if _, ok := source[field]; ok {
+		return source, field, true
+	}
+
+	for i := 0; i < len(field); i++ {
+		if field[i] != '.' {
+			continue
+		}
+
+		if child, ok := source[field[:i]].(map[string]any); ok {
+			if parent, key, ok := lookupField(child, field[i+1:]); ok {
+				return parent, key, true
+			}
+		}
+	}
+
+	return nil, "", false
+}
+
+func getField(source map[string]any, field string) (any, bool) {
+	parent, key, ok := lookupField(source, field)
+	if !ok {
+		return nil, false
+	}
+
+	return parent[key], true
+}
+
+// setField sets the dotted path field, creating missing objects.
+func setField(source map[string]any, field string, value any) {
+	if parent, key, ok := lookupField(source, field); ok {
+		parent[key] = value
+		return
+	}
+
+	names := strings.Split(field, ".")
+	for _, name := range names[:len(names)-1] {
+		child, ok := source[name].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			source[name] = child
+		}
+
+		source = child
+	}
+
+	source[names[len(names)-1]] = value
+}
+
+func deleteField(source map[string]any, field string) (any, bool) {
+	parent, key, ok := lookupField(source, field)
+	if !ok {
+		return nil, false
+	}
+
+	value := parent[key]
+	delete(parent, key)
+	return value, true
+}
+
+// renameFieldTransform moves a field, documents without the field are unchanged.
+type renameFieldTransform struct {
+	Field string `json:"field"`
+	To    string `json:"to"`
+}
+
+func (t *renameFieldTransform) validate() error {
+	if t.Field == "" || t.To == "" {
+		return errors.New("'field' and 'to' are required")
+	}
+
+	return nil
+}
+
+func (t *renameFieldTransform) apply(source map[string]any) error {
+	if value, ok := deleteField(source, t.Field); ok {
+		setField(source, t.To, value)
+	}
+
+	return nil
+}
+
+// dropFieldTransform removes fields, field patterns with wildcards match dotted paths.
+type dropFieldTransform struct {
+	Fields []string `json:"fields"`
+}
+
+func (t *dropFieldTransform) validate() error {
+	if len(t.Fields) == 0 {
+		return errors.New("'fields' is required")
+	}
+
+	return nil
+}
+
+func (t *dropFieldTransform) apply(source map[string]any) error {
+	for _, field := range t.Fields {
+		if !strings.ContainsAny(field, "*?[") {
+			deleteField(source, field)
+			continue
+		}
+
+		dropMatching(source, "", field)
+	}
+
+	return nil
+}
+
+// dropMatching removes the fields matching the pattern, matched against dotted paths.
+func dropMatching(source map[string]any, prefix, pattern string) {
+	for key, value := range source {
+		p := prefix + key
+		if ok, err := path.Match(pattern, p); err == nil && ok {
+			delete(source, key)
+			continue
+		}
+
+		if child, ok := value.(map[string]any); ok {
+			dropMatching(child, p+".", pattern)
+		}
+	}
+}
+
+// setFieldTransform sets a field to a constant value.
+type setFieldTransform struct {
+	Field string          `json:"field"`
+	Value json.RawMessage `json:"value"`
+
+	value any
+}
+
+func (t *setFieldTransform) validate() error {
+	if t.Field == "" || len(t.Value) == 0 {
+		return errors.New("'field' and 'value' are required")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(t.Value))
+	dec.UseNumber()
+	return dec.Decode(&t.value)
+}
+
+func (t *setFieldTransform) apply(source map[string]any) error {
+	setField(source, t.Field, t.value)
+	return nil
+}
+
+const (
+	castString = "string"
+	castInt    = "int"
+	castFloat  = "float"
+	castBool   = "bool"
+)
+
+// castFieldTransform converts a field to string, int, float or bool, documents without the field
+// are unchanged.
+type castFieldTransform struct {
+	Field string `json:"field"`
+	To    string `json:"to"`
+}
+
+func (t *castFieldTransform) validate() error {
+	if t.Field == "" {
+		return errors.New("'field' is required")
+	}
+
+	switch t.To {
+	case castString, castInt, castFloat, castBool:
+		return nil
+	}
+
+	return fmt.Errorf("can not cast to '%s', expecting one of 'string', 'int', 'float' or 'bool'", t.To)
+}
+
+func (t *castFieldTransform) apply(source map[string]any) error {
+	parent, key, ok := lookupField(source, t.Field)
+	if !ok || parent[key] == nil {
+		return nil
+	}
+
+	value, err := cast(parent[key], t.To)
+	if err != nil {
+		return fmt.Errorf("can not cast field '%s' to %s, %s", t.Field, t.To, err.Error())
+	}
+
+	parent[key] = value
+	return nil
+}
+
+func cast(value any, to string) (any, error) {
+	str, err := fieldString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch to {
+	case castString:
+		return str, nil
+	case castInt:
+		if n, err := strconv.ParseInt(str, 10, 64); err == nil {
+			return n, nil
+		}
+
+		// integral floats such as '2.0' or '1e3' are cast, other floats are an error rather than truncated.
+		f, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return nil, fmt.Errorf("'%s' is not an integer", str)
+		}
+
+		return int64(f), nil
+	case castFloat:
+		return strconv.ParseFloat(str, 64)
+	case castBool:
+		return strconv.ParseBool(str)
+	}
+
+	return nil, fmt.Errorf("unknown type '%s'", to)
+}
+
+// fieldString returns the string representation of a scalar field value.
+func fieldString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+
+	return "", fmt.Errorf("unexpected value of type %T", value)
+}
+
+// IDComputer is implemented by transformers computing document IDs, so destination IDs can't be
+// compared to the source IDs.
+type IDComputer interface {
+	ComputesID() bool
+}
+
+// computeIDTransform sets the document ID to the fields values joined by the separator, hashed
+// with SHA-256 if Hash is set.
+type computeIDTransform struct {
+	Fields    []string `json:"fields"`
+	Separator string   `json:"separator"`
+	Hash      bool     `json:"hash"`
+}
+
+func newComputeIDTransform(options json.RawMessage) (Transformer, error) {
+	t := &computeIDTransform{}
+	if err := json.Unmarshal(options, t); err != nil {
+		return nil, err
+	}
+
+	if len(t.Fields) == 0 {
+		return nil, errors.New("'fields' is required")
+	}
+
+	return t, nil
+}
+
+func (t *computeIDTransform) Transform(doc util.Document) (util.Document, error) {
+	source, err := decodeSource(doc.Source)
+	if err != nil {
+		return doc, err
+	}
+
+	values := make([]string, 0, len(t.Fields))
+	for _, field := range t.Fields {
+		value, ok := getField(source, field)
+		if !ok {
+			return doc, fmt.Errorf("can not compute document ID, field '%s' not found", field)
+		}
+
+		str, err := fieldString(value)
+		if err != nil {
+			return doc, fmt.Errorf("can not compute document ID from field '%s', %s", field, err.Error())
+		}
+
+		values = append(values, str)
+	}
+
+	id := strings.Join(values, t.Separator)
+	if t.Hash {
+		sum := sha256.Sum256([]byte(id))
+		id = hex.EncodeToString(sum[:])
+	}
+
+	doc.ID = id
+	return doc, nil
+}
+
+func (t *computeIDTransform) ComputesID() bool {
+	return true
+}
+
+// registeredTransforms returns the registered transform names.
+func registeredTransforms() []string {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	names := make([]string, 0, len(transforms))
+	for name := range transforms {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
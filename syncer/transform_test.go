@@ -0,0 +1,138 @@
+package syncer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+func TestParseTransforms(t *testing.T) {
+	transformer, err := ParseTransforms([]byte(`{"transforms": [
+		{"type": "rename", "field": "host", "to": "host.name"},
+		{"type": "drop", "fields": ["password", "debug.*"]},
+		{"type": "set", "field": "labels.env", "value": "staging"},
+		{"type": "cast", "field": "status", "to": "int"},
+		{"type": "cast", "field": "user.id", "to": "string"},
+		{"type": "id", "fields": ["tenant", "user.id"], "separator": "-"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := transformer.Transform(util.Document{
+		DocumentMetadata: util.DocumentMetadata{Index: "logs", ID: "1"},
+		Source: json.RawMessage(`{
+			"host": "web-1",
+			"password": "secret",
+			"debug": {"trace": "...", "level": 2},
+			"status": "200",
+			"tenant": "acme",
+			"user": {"id": 9007199254740993},
+			"labels": {"team": "search"}
+		}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.ID != "acme-9007199254740993" {
+		t.Errorf("expecting document ID 'acme-9007199254740993', got '%s'", doc.ID)
+	}
+
+	compareJSON(t, []byte(`{
+		"host": {"name": "web-1"},
+		"debug": {},
+		"status": 200,
+		"tenant": "acme",
+		"user": {"id": "9007199254740993"},
+		"labels": {"team": "search", "env": "staging"}
+	}`), doc.Source)
+}
+
+func TestParseTransformsInvalid(t *testing.T) {
+	for _, c := range []struct {
+		transforms string
+		err        string
+	}{
+		{transforms: `{"transforms": [{"type": "unknown"}]}`, err: "unknown transform #0 'unknown'"},
+		{transforms: `{"transforms": [{"type": "rename", "field": "a"}]}`, err: "'field' and 'to' are required"},
+		{transforms: `{"transforms": [{"type": "cast", "field": "a", "to": "date"}]}`, err: "can not cast to 'date'"},
+		{transforms: `{"transforms": [{"type": "id"}]}`, err: "'fields' is required"},
+	} {
+		_, err := ParseTransforms([]byte(c.transforms))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("expecting error containing '%s', got %v", c.err, err)
+		}
+	}
+}
+
+func TestCast(t *testing.T) {
+	for _, c := range []struct {
+		value    any
+		to       string
+		expected any
+		err      bool
+	}{
+		{value: "200", to: castInt, expected: int64(200)},
+		{value: json.Number("2.0"), to: castInt, expected: int64(2)},
+		{value: "1e3", to: castInt, expected: int64(1000)},
+		{value: json.Number("1.9"), to: castInt, err: true},
+		{value: "-0.5", to: castInt, err: true},
+		{value: "1e20", to: castInt, err: true},
+		{value: "abc", to: castInt, err: true},
+		{value: json.Number("1.9"), to: castFloat, expected: 1.9},
+		{value: "true", to: castBool, expected: true},
+		{value: json.Number("42"), to: castString, expected: "42"},
+	} {
+		value, err := cast(c.value, c.to)
+		if (err != nil) != c.err || value != c.expected {
+			t.Errorf("expecting %v cast to %s as %v, got %v, %v", c.value, c.to, c.expected, value, err)
+		}
+	}
+}
+
+func TestRegisterTransform(t *testing.T) {
+	RegisterTransform("upper-id", func(options json.RawMessage) (Transformer, error) {
+		return TransformerFunc(func(doc util.Document) (util.Document, error) {
+			doc.ID = strings.ToUpper(doc.ID)
+			return doc, nil
+		}), nil
+	})
+
+	transformer, err := ParseTransforms([]byte(`{"transforms": [{"type": "upper-id"}, {"type": "id", "fields": ["id"], "hash": true}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := transformer.Transform(util.Document{
+		DocumentMetadata: util.DocumentMetadata{ID: "abc"},
+		Source:           json.RawMessage(`{"name": "foo"}`),
+	})
+	if err == nil || !strings.Contains(err.Error(), "field 'id' not found") {
+		t.Errorf("expecting missing field error, got %v", err)
+	}
+
+	if doc.ID != "ABC" {
+		t.Errorf("expecting document ID 'ABC', got '%s'", doc.ID)
+	}
+}
+
+func compareJSON(t *testing.T, expected, actual []byte) {
+	t.Helper()
+	var e, a any
+	if err := json.Unmarshal(expected, &e); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(actual, &a); err != nil {
+		t.Fatal(err)
+	}
+
+	eb, _ := json.Marshal(e)
+	ab, _ := json.Marshal(a)
+	if string(eb) != string(ab) {
+		t.Errorf("expecting %s, got %s", eb, ab)
+	}
+}
@@ -0,0 +1,373 @@
+package syncer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	util "github.com/rkspx/elastic-syncer/elasticsearch-util"
+)
+
+const (
+	dateHistogramAggregation = "buckets"
+
+	// DefaultVerifyInterval is the default interval of the compared time buckets.
+	DefaultVerifyInterval = "1d"
+
+	// maxDocumentMismatches is the number of differing documents reported per index.
+	maxDocumentMismatches = 100
+)
+
+type VerifyConfig struct {
+	Since     time.Duration
+	Index     string
+	TimeField string
+
+	// Query and QueryString select the compared documents, as they select the synced documents.
+	Query       json.RawMessage
+	QueryString string
+
+	// Rename are the rename rules of the sync, see Config.Rename.
+	Rename []string
+
+	// Interval is the `date_histogram` interval of the compared time buckets, e.g. `1h` or `1d`.
+	Interval string
+
+	// Hashes compares the `_source` hash of every document in addition to document counts.
+	// Documents are compared as is, so they differ if the sync transforms them.
+	Hashes   bool
+	PageSize int
+
+	FromHost         string
+	FromUsername     string
+	FromPassword     string
+	LogFromRequests  bool
+	LogFromResponses bool
+
+	ToHost         string
+	ToUsername     string
+	ToPassword     string
+	LogToRequests  bool
+	LogToResponses bool
+
+	// Logger writes the logs, defaults to text logs of at least info level on stderr.
+	Logger Logger
+}
+
+// Verifier compares the indices of the source with their copy on the destination.
+type Verifier struct {
+	source *readClient
+	dest   *readClient
+
+	index     string
+	from      time.Time
+	to        time.Time
+	timeField string
+
+	filter      json.RawMessage
+	queryString string
+	renames     renameRules
+
+	interval string
+	hashes   bool
+	pageSize int
+
+	logger Logger
+}
+
+func NewVerifier(cfg VerifyConfig) (*Verifier, error) {
+	cfg.Logger = loggerOrDefault(cfg.Logger)
+	source, err := newReadClient(readClientConfig{
+		address:      cfg.FromHost,
+		username:     cfg.FromUsername,
+		password:     cfg.FromPassword,
+		logRequests:  cfg.LogFromRequests,
+		logResponses: cfg.LogFromResponses,
+		logger:       cfg.Logger.With("cluster", "source"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create from client, %s", err.Error())
+	}
+
+	dest, err := newReadClient(readClientConfig{
+		address:      cfg.ToHost,
+		username:     cfg.ToUsername,
+		password:     cfg.ToPassword,
+		logRequests:  cfg.LogToRequests,
+		logResponses: cfg.LogToResponses,
+		logger:       cfg.Logger.With("cluster", "destination"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create to client, %s", err.Error())
+	}
+
+	filter, err := parseQuery(cfg.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	renames, err := parseRenameRules(cfg.Rename)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Interval == "" {
+		cfg.Interval = DefaultVerifyInterval
+	}
+
+	if cfg.PageSize == 0 {
+		cfg.PageSize = DefaultPageSize
+	}
+
+	now := time.Now().UTC()
+	return &Verifier{
+		source:      source,
+		dest:        dest,
+		index:       cfg.Index,
+		from:        now.Add(-cfg.Since),
+		to:          now,
+		timeField:   cfg.TimeField,
+		filter:      filter,
+		queryString: cfg.QueryString,
+		renames:     renames,
+		interval:    cfg.Interval,
+		hashes:      cfg.Hashes,
+		pageSize:    cfg.PageSize,
+		logger:      cfg.Logger,
+	}, nil
+}
+
+// Mismatch is a difference between a source index and its destination index. Scope is either
+// `count`, a time bucket, or a document ID.
+type Mismatch struct {
+	Index       string
+	Destination string
+	Scope       string
+	SourceValue string
+	DestValue   string
+}
+
+// VerifyReport lists the mismatches of every verified index.
+type VerifyReport struct {
+	Indices    int
+	Mismatches []Mismatch
+}
+
+// Diverged reports whether any index differs between source and destination.
+func (r VerifyReport) Diverged() bool {
+	return len(r.Mismatches) > 0
+}
+
+// WriteTable writes the mismatches as a table.
+func (r VerifyReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "INDEX\tDESTINATION\tSCOPE\tSOURCE\tDESTINATION")
+	for _, m := range r.Mismatches {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", m.Index, m.Destination, m.Scope, m.SourceValue, m.DestValue)
+	}
+
+	return tw.Flush()
+}
+
+// Verify compares the document count of every index, then the document count per time bucket of
+// indices with a time field, and the `_source` hash of every document if enabled.
+func (v *Verifier) Verify(ctx context.Context) (VerifyReport, error) {
+	settings, err := v.source.ReadIndexSettings(ctx, v.index)
+	if err != nil {
+		return VerifyReport{}, fmt.Errorf("can not get index settings for '%s', %s", v.index, err.Error())
+	}
+
+	report := VerifyReport{Indices: len(settings)}
+	for _, setting := range settings {
+		mismatches, err := v.verifyIndex(ctx, setting)
+		if err != nil {
+			return report, fmt.Errorf("can not verify index '%s', %s", setting.Index, err.Error())
+		}
+
+		report.Mismatches = append(report.Mismatches, mismatches...)
+	}
+
+	return report, nil
+}
+
+func (v *Verifier) verifyIndex(ctx context.Context, setting util.IndexSetting) ([]Mismatch, error) {
+	dest := v.renames.apply(setting.Index)
+	mismatch := func(scope, source, destination string) Mismatch {
+		return Mismatch{Index: setting.Index, Destination: dest, Scope: scope, SourceValue: source, DestValue: destination}
+	}
+
+	timeField, err := resolveTimeField(setting.Setting.Mappings, v.timeField)
+	if err != nil {
+		return nil, err
+	}
+
+	req := readAllRequest{
+		from:        v.from,
+		to:          v.to,
+		index:       setting.Index,
+		timeField:   timeField,
+		filter:      v.filter,
+		queryString: v.queryString,
+	}
+	query := req.query()
+
+	v.logger.With("index", setting.Index).Infof("verifying index against destination index '%s'", dest)
+	sourceCount, err := v.source.Count(ctx, setting.Index, query)
+	if err != nil {
+		return nil, fmt.Errorf("can not count source documents, %s", err.Error())
+	}
+
+	destCount, err := v.dest.Count(ctx, dest, query)
+	var resErr util.CommonErrorResponse
+	if errors.As(err, &resErr) && resErr.Status == http.StatusNotFound {
+		return []Mismatch{mismatch("count", strconv.FormatInt(sourceCount, 10), "missing index")}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("can not count destination documents, %s", err.Error())
+	}
+
+	var mismatches []Mismatch
+	if sourceCount != destCount {
+		mismatches = append(mismatches, mismatch("count", strconv.FormatInt(sourceCount, 10), strconv.FormatInt(destCount, 10)))
+	}
+
+	if req.timeField != "" {
+		buckets, err := v.compareBuckets(ctx, req.index, dest, query, req.timeField, mismatch)
+		if err != nil {
+			return nil, err
+		}
+
+		mismatches = append(mismatches, buckets...)
+	}
+
+	if v.hashes {
+		docs, err := v.compareHashes(ctx, req.index, dest, query, mismatch)
+		if err != nil {
+			return nil, err
+		}
+
+		mismatches = append(mismatches, docs...)
+	}
+
+	return mismatches, nil
+}
+
+// compareBuckets returns the time buckets with different document counts.
+func (v *Verifier) compareBuckets(ctx context.Context, index, dest string, query any, timeField string, mismatch func(scope, source, dest string) Mismatch) ([]Mismatch, error) {
+	sourceBuckets, err := v.source.DateHistogram(ctx, index, query, timeField, v.interval)
+	if err != nil {
+		return nil, fmt.Errorf("can not get source time buckets, %s", err.Error())
+	}
+
+	destBuckets, err := v.dest.DateHistogram(ctx, dest, query, timeField, v.interval)
+	if err != nil {
+		return nil, fmt.Errorf("can not get destination time buckets, %s", err.Error())
+	}
+
+	var mismatches []Mismatch
+	bucket := func(b util.DateHistogramBucket) string {
+		return time.UnixMilli(b.Key).UTC().Format(time.RFC3339)
+	}
+
+	// buckets are sorted by key on both sides.
+	i, j := 0, 0
+	for i < len(sourceBuckets) || j < len(destBuckets) {
+		switch {
+		case j == len(destBuckets) || (i < len(sourceBuckets) && sourceBuckets[i].Key < destBuckets[j].Key):
+			mismatches = append(mismatches, mismatch(bucket(sourceBuckets[i]), strconv.FormatInt(sourceBuckets[i].DocCount, 10), "0"))
+			i++
+		case i == len(sourceBuckets) || destBuckets[j].Key < sourceBuckets[i].Key:
+			mismatches = append(mismatches, mismatch(bucket(destBuckets[j]), "0", strconv.FormatInt(destBuckets[j].DocCount, 10)))
+			j++
+		default:
+			if sourceBuckets[i].DocCount != destBuckets[j].DocCount {
+				mismatches = append(mismatches, mismatch(bucket(sourceBuckets[i]), strconv.FormatInt(sourceBuckets[i].DocCount, 10), strconv.FormatInt(destBuckets[j].DocCount, 10)))
+			}
+
+			i++
+			j++
+		}
+	}
+
+	return mismatches, nil
+}
+
+// compareHashes returns the documents missing on either side, or with a different `_source`, up to
+// maxDocumentMismatches and a summary of the others.
+func (v *Verifier) compareHashes(ctx context.Context, index, dest string, query any, mismatch func(scope, source, dest string) Mismatch) ([]Mismatch, error) {
+	source := newIDScanner(v.source.cl, index, query, v.pageSize)
+	destination := newIDScanner(v.dest.cl, dest, query, v.pageSize)
+	source.source, destination.source = true, true
+
+	var mismatches []Mismatch
+	differ := 0
+	add := func(id, s, d string) {
+		differ++
+		if differ <= maxDocumentMismatches {
+			mismatches = append(mismatches, mismatch("_id "+id, s, d))
+		}
+	}
+
+	s, sok, err := source.next(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can not read source documents, %s", err.Error())
+	}
+
+	d, dok, err := destination.next(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can not read destination documents, %s", err.Error())
+	}
+
+	for sok || dok {
+		switch {
+		case !dok || (sok && s.ID < d.ID):
+			add(s.ID, sourceHash(s), "missing")
+			s, sok, err = source.next(ctx)
+		case !sok || d.ID < s.ID:
+			add(d.ID, "missing", sourceHash(d))
+			d, dok, err = destination.next(ctx)
+		default:
+			if sh, dh := sourceHash(s), sourceHash(d); sh != dh {
+				add(s.ID, sh, dh)
+			}
+
+			s, sok, err = source.next(ctx)
+			if err == nil {
+				d, dok, err = destination.next(ctx)
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("can not read documents, %s", err.Error())
+		}
+	}
+
+	if differ > maxDocumentMismatches {
+		mismatches = append(mismatches, mismatch("documents", "...", fmt.Sprintf("%d more differ", differ-maxDocumentMismatches)))
+	}
+
+	return mismatches, nil
+}
+
+// sourceHash returns the SHA-256 of the document `_source` with sorted keys, so documents with
+// fields in a different order have the same hash. It's shortened for display.
+func sourceHash(doc util.Document) string {
+	b := []byte(doc.Source)
+	if source, err := decodeSource(doc.Source); err == nil {
+		if canonical, err := json.Marshal(source); err == nil {
+			b = canonical
+		}
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
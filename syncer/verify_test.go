@@ -0,0 +1,142 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// verifyServer serves an index of documents, keyed by ID, with their time bucket.
+func verifyServer(t *testing.T, index string, docs map[string]string, buckets map[int64]int64) *httptest.Server {
+	ids := make([]string, 0, len(docs))
+	for id := range docs {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`{"version": {"number": "7.17.1"}}`))
+		case "/" + index:
+			fmt.Fprintf(w, `{"%s": {"aliases": {}, "mappings": {"properties": {"@timestamp": {"type": "date"}}}, "settings": {}}}`, index)
+		case "/" + index + "/_count":
+			fmt.Fprintf(w, `{"count": %d}`, len(docs))
+		case "/" + index + "/_search":
+			var body struct {
+				Aggs json.RawMessage `json:"aggs"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Error(err)
+			}
+
+			if len(body.Aggs) > 0 {
+				keys := make([]int64, 0, len(buckets))
+				for key := range buckets {
+					keys = append(keys, key)
+				}
+
+				sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+				items := []string{}
+				for _, key := range keys {
+					items = append(items, fmt.Sprintf(`{"key": %d, "doc_count": %d}`, key, buckets[key]))
+				}
+
+				fmt.Fprintf(w, `{"hits": {"hits": []}, "aggregations": {"buckets": {"buckets": [%s]}}}`, strings.Join(items, ","))
+				return
+			}
+
+			hits := []string{}
+			for _, id := range ids {
+				hits = append(hits, fmt.Sprintf(`{"_index": "%s", "_id": "%s", "_source": %s, "sort": ["%s"]}`, index, id, docs[id], id))
+			}
+
+			fmt.Fprintf(w, `{"hits": {"hits": [%s]}}`, strings.Join(hits, ","))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"type": "index_not_found_exception", "reason": "no such index"}, "status": 404}`))
+		}
+	}))
+}
+
+func TestVerify(t *testing.T) {
+	source := verifyServer(t, "logs", map[string]string{
+		"1": `{"message": "foo", "level": "info"}`,
+		"2": `{"message": "bar"}`,
+		"3": `{"message": "baz"}`,
+	}, map[int64]int64{1672531200000: 2, 1672617600000: 1})
+	defer source.Close()
+
+	dest := verifyServer(t, "logs-copy", map[string]string{
+		"1": `{"level": "info", "message": "foo"}`,
+		"2": `{"message": "qux"}`,
+	}, map[int64]int64{1672531200000: 2})
+	defer dest.Close()
+
+	v, err := NewVerifier(VerifyConfig{
+		Index:    "logs",
+		Rename:   []string{"suffix:=-copy"},
+		Hashes:   true,
+		PageSize: 10,
+		FromHost: source.URL,
+		ToHost:   dest.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := v.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Diverged() {
+		t.Fatal("expecting divergence")
+	}
+
+	var b bytes.Buffer
+	if err := report.WriteTable(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := map[string][]string{}
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n")[1:] {
+		fields := strings.Fields(line)
+		rows[strings.Join(fields[2:len(fields)-2], " ")] = fields
+	}
+
+	for scope, expected := range map[string][]string{
+		"count":                {"logs", "logs-copy", "count", "3", "2"},
+		"2023-01-02T00:00:00Z": {"logs", "logs-copy", "2023-01-02T00:00:00Z", "1", "0"},
+	} {
+		if strings.Join(rows[scope], " ") != strings.Join(expected, " ") {
+			t.Errorf("expecting row %v, got %v", expected, rows[scope])
+		}
+	}
+
+	if row := rows["_id 3"]; len(row) != 6 || row[5] != "missing" {
+		t.Errorf("expecting document '3' missing on destination, got %v", row)
+	}
+
+	if row := rows["_id 2"]; len(row) != 6 || row[4] == row[5] {
+		t.Errorf("expecting document '2' with different hashes, got %v", row)
+	}
+
+	if len(report.Mismatches) != 4 {
+		t.Errorf("expecting 4 mismatches, got %+v", report.Mismatches)
+	}
+
+	for _, m := range report.Mismatches {
+		if m.Scope == "_id 1" {
+			t.Errorf("expecting document '1' to have the same hash, got %+v", m)
+		}
+	}
+}